@@ -1,36 +1,469 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
 )
 
+type APIKey struct {
+	Name string `yaml:"name" json:"name"`
+	Role string `yaml:"role" json:"role"`
+	Key  string `yaml:"key" json:"key"`
+}
+
+// RegistryAuth holds the credentials used to pull from one registry host,
+// e.g. "docker.io" or "registry.example.com:5000".
+type RegistryAuth struct {
+	Server   string `yaml:"server" json:"server"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" json:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" json:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" json:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials"`
+}
+
 type Config struct {
-	ServerPort string
-	DBPath     string
-	BaseDir    string
+	ServerPort string   `yaml:"server_port" json:"server_port"`
+	DBPath     string   `yaml:"db_path" json:"db_path"`
+	BaseDir    string   `yaml:"base_dir" json:"base_dir"`
+	APIKeys    []APIKey `yaml:"api_keys" json:"api_keys"`
+
+	CORS CORSConfig `yaml:"cors" json:"cors"`
+
+	DockerImage   string        `yaml:"docker_image" json:"docker_image"`
+	DockerNetwork string        `yaml:"docker_network" json:"docker_network"`
+	PortBase      int           `yaml:"port_base" json:"port_base"`
+	StopTimeout   time.Duration `yaml:"stop_timeout" json:"stop_timeout"`
+	LogWait       time.Duration `yaml:"log_wait" json:"log_wait"`
+
+	// ImagePullPolicy is if-missing, always or never; "never" expects
+	// images to be loaded from a tarball on air-gapped hosts.
+	ImagePullPolicy string         `yaml:"image_pull_policy" json:"image_pull_policy"`
+	RegistryAuth    []RegistryAuth `yaml:"registry_auth" json:"registry_auth"`
+	ImageLoadMaxMB  int            `yaml:"image_load_max_mb" json:"image_load_max_mb"`
+
+	// With NetworkIsolation each tenant gets its own bridge network. The
+	// containers in TenantNetworkAttach (blackbox, and the manager itself
+	// when it runs in a container) are connected to every tenant network.
+	// TenantEgress "deny" makes tenant networks internal, which also means
+	// published ports stop working and tenants are reached via the proxy.
+	NetworkIsolation    bool     `yaml:"network_isolation" json:"network_isolation"`
+	TenantNetworkAttach []string `yaml:"tenant_network_attach" json:"tenant_network_attach"`
+	TenantEgress        string   `yaml:"tenant_egress" json:"tenant_egress"`
+
+	// Hardening for new tenant containers. Each tenant runs as UID and GID
+	// tenant_uid_base + port; a base of 0 keeps running FileBrowser as
+	// root. The profile is recorded on the tenant when it is created.
+	TenantUIDBase         int      `yaml:"tenant_uid_base" json:"tenant_uid_base"`
+	TenantCapDrop         []string `yaml:"tenant_cap_drop" json:"tenant_cap_drop"`
+	TenantNoNewPrivileges bool     `yaml:"tenant_no_new_privileges" json:"tenant_no_new_privileges"`
+	TenantReadOnlyRootfs  bool     `yaml:"tenant_read_only_rootfs" json:"tenant_read_only_rootfs"`
+	TenantTmpfs           []string `yaml:"tenant_tmpfs" json:"tenant_tmpfs"`
+	TenantSeccompProfile  string   `yaml:"tenant_seccomp_profile" json:"tenant_seccomp_profile"`
+	TenantAppArmorProfile string   `yaml:"tenant_apparmor_profile" json:"tenant_apparmor_profile"`
+
+	// UpgradeHealthTimeout is how long an upgraded tenant has to pass its
+	// health check before the upgrade is rolled back.
+	UpgradeHealthTimeout time.Duration `yaml:"upgrade_health_timeout" json:"upgrade_health_timeout"`
+
+	// DrainTimeout bounds how long shutdown waits for in-flight tenant
+	// operations before cancelling and rolling them back.
+	DrainTimeout time.Duration `yaml:"drain_timeout" json:"drain_timeout"`
+
+	ProbeInterval         time.Duration `yaml:"probe_interval" json:"probe_interval"`
+	ProbeTimeout          time.Duration `yaml:"probe_timeout" json:"probe_timeout"`
+	ProbeFailureThreshold int           `yaml:"probe_failure_threshold" json:"probe_failure_threshold"`
+	ProbeTarget           string        `yaml:"probe_target" json:"probe_target"`
+	ProbeHost             string        `yaml:"probe_host" json:"probe_host"`
+	ProbeRetention        time.Duration `yaml:"probe_retention" json:"probe_retention"`
+
+	RecoveryPolicy           string        `yaml:"recovery_policy" json:"recovery_policy"`
+	RecoveryInterval         time.Duration `yaml:"recovery_interval" json:"recovery_interval"`
+	RecoveryWindow           time.Duration `yaml:"recovery_window" json:"recovery_window"`
+	RecoveryRestartThreshold int           `yaml:"recovery_restart_threshold" json:"recovery_restart_threshold"`
+	RecoveryMaxAttempts      int           `yaml:"recovery_max_attempts" json:"recovery_max_attempts"`
+
+	IdleCheckInterval    time.Duration `yaml:"idle_check_interval" json:"idle_check_interval"`
+	IdleTrafficThreshold int           `yaml:"idle_traffic_threshold" json:"idle_traffic_threshold"`
+	WakeTimeout          time.Duration `yaml:"wake_timeout" json:"wake_timeout"`
+
+	// OrphanGCPolicy is "report" to only list leftovers or "delete" to
+	// remove them once they have been orphaned for the grace period.
+	OrphanGCPolicy      string        `yaml:"orphan_gc_policy" json:"orphan_gc_policy"`
+	OrphanGCInterval    time.Duration `yaml:"orphan_gc_interval" json:"orphan_gc_interval"`
+	OrphanGCGracePeriod time.Duration `yaml:"orphan_gc_grace_period" json:"orphan_gc_grace_period"`
+
+	// The proxy reaches containers by name, so the manager has to be
+	// attached to the same Docker network as the tenants.
+	ProxyEnabled    bool   `yaml:"proxy_enabled" json:"proxy_enabled"`
+	ProxyMode       string `yaml:"proxy_mode" json:"proxy_mode"`
+	ProxyBaseDomain string `yaml:"proxy_base_domain" json:"proxy_base_domain"`
+	ProxyPublicURL  string `yaml:"proxy_public_url" json:"proxy_public_url"`
+	PublishPorts    bool   `yaml:"publish_ports" json:"publish_ports"`
+
+	TenantURLTemplate    string `yaml:"tenant_url_template" json:"tenant_url_template"`
+	PublicHost           string `yaml:"public_host" json:"public_host"`
+	CustomDomainScheme   string `yaml:"custom_domain_scheme" json:"custom_domain_scheme"`
+	PrometheusTargetMode string `yaml:"prometheus_target_mode" json:"prometheus_target_mode"`
+
+	TLSCertFile       string        `yaml:"tls_cert_file" json:"tls_cert_file"`
+	TLSKeyFile        string        `yaml:"tls_key_file" json:"tls_key_file"`
+	TLSClientCAFile   string        `yaml:"tls_client_ca_file" json:"tls_client_ca_file"`
+	TLSClientAuth     string        `yaml:"tls_client_auth" json:"tls_client_auth"`
+	TLSClientRole     string        `yaml:"tls_client_role" json:"tls_client_role"`
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval" json:"tls_reload_interval"`
+
+	ACMEEnabled      bool     `yaml:"acme_enabled" json:"acme_enabled"`
+	ACMEDomains      []string `yaml:"acme_domains" json:"acme_domains"`
+	ACMEEmail        string   `yaml:"acme_email" json:"acme_email"`
+	ACMEDirectoryURL string   `yaml:"acme_directory_url" json:"acme_directory_url"`
+	ACMECacheDir     string   `yaml:"acme_cache_dir" json:"acme_cache_dir"`
+	ACMECARoot       string   `yaml:"acme_ca_root" json:"acme_ca_root"`
+	ACMEHTTPAddr     string   `yaml:"acme_http_addr" json:"acme_http_addr"`
+
+	// File is the config file the settings were read from, if any.
+	File string `yaml:"-" json:"file,omitempty"`
+
+	// envErrors lists environment variables that could not be parsed;
+	// Validate reports them with its other problems.
+	envErrors []string
+}
+
+func (c *Config) TLSEnabled() bool {
+	return c.ACMEEnabled || (c.TLSCertFile != "" && c.TLSKeyFile != "")
 }
 
-func LoadConfig() *Config {
+func defaultConfig() *Config {
 	baseDir, err := os.Getwd()
 	if err != nil {
 		baseDir = "."
 	}
 
-	serverPort := os.Getenv("SERVER_PORT")
-	if serverPort == "" {
-		serverPort = "8081"
+	return &Config{
+		ServerPort: "8081",
+		BaseDir:    baseDir,
+		APIKeys:    []APIKey{},
+
+		CORS: CORSConfig{
+			AllowedOrigins:   []string{"*"},
+			AllowedMethods:   []string{"POST", "OPTIONS", "GET", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "X-API-Key", "accept", "origin", "Cache-Control", "X-Requested-With"},
+			AllowCredentials: true,
+		},
+
+		DockerImage:   "filebrowser/filebrowser",
+		DockerNetwork: "monitoring",
+		PortBase:      9000,
+		StopTimeout:   10 * time.Second,
+		LogWait:       2 * time.Second,
+		DrainTimeout:  30 * time.Second,
+
+		ImagePullPolicy: "if-missing",
+		RegistryAuth:    []RegistryAuth{},
+		ImageLoadMaxMB:  4096,
+
+		UpgradeHealthTimeout: 2 * time.Minute,
+
+		TenantUIDBase:         100000,
+		TenantCapDrop:         []string{"ALL"},
+		TenantNoNewPrivileges: true,
+		TenantReadOnlyRootfs:  true,
+		TenantTmpfs:           []string{"/tmp"},
+
+		TenantNetworkAttach: []string{"blackbox"},
+		TenantEgress:        "allow",
+
+		ProbeInterval:         30 * time.Second,
+		ProbeTimeout:          5 * time.Second,
+		ProbeFailureThreshold: 3,
+		ProbeTarget:           "host",
+		ProbeHost:             "localhost",
+		ProbeRetention:        7 * 24 * time.Hour,
+
+		RecoveryPolicy:           "restart",
+		RecoveryInterval:         30 * time.Second,
+		RecoveryWindow:           10 * time.Minute,
+		RecoveryRestartThreshold: 3,
+		RecoveryMaxAttempts:      3,
+
+		IdleCheckInterval:    time.Minute,
+		IdleTrafficThreshold: 16 * 1024,
+		WakeTimeout:          60 * time.Second,
+
+		OrphanGCPolicy:      "report",
+		OrphanGCInterval:    time.Hour,
+		OrphanGCGracePeriod: 24 * time.Hour,
+
+		ProxyMode:    "path",
+		PublishPorts: true,
+
+		PublicHost:           "localhost",
+		CustomDomainScheme:   "https",
+		PrometheusTargetMode: "internal",
+
+		TLSClientAuth:     "request",
+		TLSClientRole:     "operator",
+		TLSReloadInterval: 30 * time.Second,
+
+		ACMEDomains:      []string{},
+		ACMEDirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
 	}
+}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = filepath.Join(baseDir, "filebrowser_db", "filebrowser.db")
+// LoadConfig builds the configuration from defaults, the optional YAML
+// file and environment variables, in that order of precedence.
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Config{
-		ServerPort: serverPort,
-		DBPath:     dbPath,
-		BaseDir:    baseDir,
+	cfg.applyDerivedDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+
+	return cfg, nil
+}
+
+func readConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		cfg.File = path
+	}
+
+	applyEnv(cfg)
+	return cfg, nil
 }
 
+// applyDerivedDefaults fills in settings whose defaults depend on others.
+func (c *Config) applyDerivedDefaults() {
+	if c.DBPath == "" {
+		c.DBPath = filepath.Join(c.BaseDir, "filebrowser_db", "filebrowser.db")
+	}
+	if c.ProxyPublicURL == "" {
+		c.ProxyPublicURL = "http://localhost:" + c.ServerPort
+	}
+}
+
+func applyEnv(cfg *Config) {
+	cfg.ServerPort = getEnv("SERVER_PORT", cfg.ServerPort)
+	cfg.DBPath = getEnv("DB_PATH", cfg.DBPath)
+	cfg.BaseDir = getEnv("BASE_DIR", cfg.BaseDir)
+	if raw := os.Getenv("API_KEYS"); raw != "" {
+		keys, err := parseAPIKeys(raw)
+		if err != nil {
+			cfg.envErrors = append(cfg.envErrors, err.Error())
+		}
+		cfg.APIKeys = keys
+	}
+
+	cfg.CORS.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", cfg.CORS.AllowedOrigins)
+	cfg.CORS.AllowedMethods = getEnvList("CORS_ALLOWED_METHODS", cfg.CORS.AllowedMethods)
+	cfg.CORS.AllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", cfg.CORS.AllowedHeaders)
+	cfg.CORS.AllowCredentials = cfg.getEnvBool("CORS_ALLOW_CREDENTIALS", cfg.CORS.AllowCredentials)
+
+	cfg.DockerImage = getEnv("DOCKER_IMAGE", cfg.DockerImage)
+	cfg.DockerNetwork = getEnv("DOCKER_NETWORK", cfg.DockerNetwork)
+	cfg.PortBase = cfg.getEnvInt("PORT_BASE", cfg.PortBase)
+	cfg.StopTimeout = cfg.getEnvDuration("STOP_TIMEOUT", cfg.StopTimeout)
+	cfg.LogWait = cfg.getEnvDuration("LOG_WAIT", cfg.LogWait)
+	cfg.ImagePullPolicy = getEnv("IMAGE_PULL_POLICY", cfg.ImagePullPolicy)
+	if raw := os.Getenv("REGISTRY_AUTH"); raw != "" {
+		cfg.RegistryAuth = parseRegistryAuth(raw)
+	}
+	cfg.ImageLoadMaxMB = cfg.getEnvInt("IMAGE_LOAD_MAX_MB", cfg.ImageLoadMaxMB)
+	cfg.DrainTimeout = cfg.getEnvDuration("DRAIN_TIMEOUT", cfg.DrainTimeout)
+	cfg.UpgradeHealthTimeout = cfg.getEnvDuration("UPGRADE_HEALTH_TIMEOUT", cfg.UpgradeHealthTimeout)
+	cfg.TenantUIDBase = cfg.getEnvInt("TENANT_UID_BASE", cfg.TenantUIDBase)
+	cfg.TenantCapDrop = getEnvList("TENANT_CAP_DROP", cfg.TenantCapDrop)
+	cfg.TenantNoNewPrivileges = cfg.getEnvBool("TENANT_NO_NEW_PRIVILEGES", cfg.TenantNoNewPrivileges)
+	cfg.TenantReadOnlyRootfs = cfg.getEnvBool("TENANT_READ_ONLY_ROOTFS", cfg.TenantReadOnlyRootfs)
+	cfg.TenantTmpfs = getEnvList("TENANT_TMPFS", cfg.TenantTmpfs)
+	cfg.TenantSeccompProfile = getEnv("TENANT_SECCOMP_PROFILE", cfg.TenantSeccompProfile)
+	cfg.TenantAppArmorProfile = getEnv("TENANT_APPARMOR_PROFILE", cfg.TenantAppArmorProfile)
+	cfg.NetworkIsolation = cfg.getEnvBool("NETWORK_ISOLATION", cfg.NetworkIsolation)
+	cfg.TenantNetworkAttach = getEnvList("TENANT_NETWORK_ATTACH", cfg.TenantNetworkAttach)
+	cfg.TenantEgress = getEnv("TENANT_EGRESS", cfg.TenantEgress)
+
+	cfg.ProbeInterval = cfg.getEnvDuration("PROBE_INTERVAL", cfg.ProbeInterval)
+	cfg.ProbeTimeout = cfg.getEnvDuration("PROBE_TIMEOUT", cfg.ProbeTimeout)
+	cfg.ProbeFailureThreshold = cfg.getEnvInt("PROBE_FAILURE_THRESHOLD", cfg.ProbeFailureThreshold)
+	cfg.ProbeTarget = getEnv("PROBE_TARGET", cfg.ProbeTarget)
+	cfg.ProbeHost = getEnv("PROBE_HOST", cfg.ProbeHost)
+	cfg.ProbeRetention = cfg.getEnvDuration("PROBE_RETENTION", cfg.ProbeRetention)
+
+	cfg.RecoveryPolicy = getEnv("RECOVERY_POLICY", cfg.RecoveryPolicy)
+	cfg.RecoveryInterval = cfg.getEnvDuration("RECOVERY_INTERVAL", cfg.RecoveryInterval)
+	cfg.RecoveryWindow = cfg.getEnvDuration("RECOVERY_WINDOW", cfg.RecoveryWindow)
+	cfg.RecoveryRestartThreshold = cfg.getEnvInt("RECOVERY_RESTART_THRESHOLD", cfg.RecoveryRestartThreshold)
+	cfg.RecoveryMaxAttempts = cfg.getEnvInt("RECOVERY_MAX_ATTEMPTS", cfg.RecoveryMaxAttempts)
+
+	cfg.IdleCheckInterval = cfg.getEnvDuration("IDLE_CHECK_INTERVAL", cfg.IdleCheckInterval)
+	cfg.IdleTrafficThreshold = cfg.getEnvInt("IDLE_TRAFFIC_THRESHOLD", cfg.IdleTrafficThreshold)
+	cfg.WakeTimeout = cfg.getEnvDuration("WAKE_TIMEOUT", cfg.WakeTimeout)
+
+	cfg.OrphanGCPolicy = getEnv("ORPHAN_GC_POLICY", cfg.OrphanGCPolicy)
+	cfg.OrphanGCInterval = cfg.getEnvDuration("ORPHAN_GC_INTERVAL", cfg.OrphanGCInterval)
+	cfg.OrphanGCGracePeriod = cfg.getEnvDuration("ORPHAN_GC_GRACE_PERIOD", cfg.OrphanGCGracePeriod)
+
+	cfg.ProxyEnabled = cfg.getEnvBool("PROXY_ENABLED", cfg.ProxyEnabled)
+	cfg.ProxyMode = getEnv("PROXY_MODE", cfg.ProxyMode)
+	cfg.ProxyBaseDomain = getEnv("PROXY_BASE_DOMAIN", cfg.ProxyBaseDomain)
+	cfg.ProxyPublicURL = getEnv("PROXY_PUBLIC_URL", cfg.ProxyPublicURL)
+	cfg.PublishPorts = cfg.getEnvBool("PUBLISH_PORTS", cfg.PublishPorts)
+
+	cfg.TenantURLTemplate = getEnv("TENANT_URL_TEMPLATE", cfg.TenantURLTemplate)
+	cfg.PublicHost = getEnv("PUBLIC_HOST", cfg.PublicHost)
+	cfg.CustomDomainScheme = getEnv("CUSTOM_DOMAIN_SCHEME", cfg.CustomDomainScheme)
+	cfg.PrometheusTargetMode = getEnv("PROMETHEUS_TARGET_MODE", cfg.PrometheusTargetMode)
+
+	cfg.TLSCertFile = getEnv("TLS_CERT_FILE", cfg.TLSCertFile)
+	cfg.TLSKeyFile = getEnv("TLS_KEY_FILE", cfg.TLSKeyFile)
+	cfg.TLSClientCAFile = getEnv("TLS_CLIENT_CA_FILE", cfg.TLSClientCAFile)
+	cfg.TLSClientAuth = getEnv("TLS_CLIENT_AUTH", cfg.TLSClientAuth)
+	cfg.TLSClientRole = getEnv("TLS_CLIENT_ROLE", cfg.TLSClientRole)
+	cfg.TLSReloadInterval = cfg.getEnvDuration("TLS_RELOAD_INTERVAL", cfg.TLSReloadInterval)
+
+	cfg.ACMEEnabled = cfg.getEnvBool("ACME_ENABLED", cfg.ACMEEnabled)
+	cfg.ACMEDomains = getEnvList("ACME_DOMAINS", cfg.ACMEDomains)
+	cfg.ACMEEmail = getEnv("ACME_EMAIL", cfg.ACMEEmail)
+	cfg.ACMEDirectoryURL = getEnv("ACME_DIRECTORY_URL", cfg.ACMEDirectoryURL)
+	cfg.ACMECacheDir = getEnv("ACME_CACHE_DIR", cfg.ACMECacheDir)
+	cfg.ACMECARoot = getEnv("ACME_CA_ROOT", cfg.ACMECARoot)
+	cfg.ACMEHTTPAddr = getEnv("ACME_HTTP_ADDR", cfg.ACMEHTTPAddr)
+}
+
+// parseAPIKeys reads entries in the form "name:role:key" separated by
+// commas. Malformed entries are reported by position rather than dropped,
+// as losing every key would leave the API open.
+func parseAPIKeys(raw string) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	var malformed []string
+	for i, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			malformed = append(malformed, strconv.Itoa(i+1))
+			continue
+		}
+
+		keys = append(keys, APIKey{
+			Name: parts[0],
+			Role: parts[1],
+			Key:  parts[2],
+		})
+	}
+	if len(malformed) > 0 {
+		return keys, fmt.Errorf("API_KEYS entries %s are not in the form name:role:key", strings.Join(malformed, ", "))
+	}
+	return keys, nil
+}
+
+// parseRegistryAuth reads entries in the form "username:password@server"
+// separated by commas. The password may contain ":" and "@".
+func parseRegistryAuth(raw string) []RegistryAuth {
+	auths := make([]RegistryAuth, 0)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		at := strings.LastIndex(entry, "@")
+		if at < 0 {
+			continue
+		}
+
+		username, password, ok := strings.Cut(entry[:at], ":")
+		if !ok || username == "" || entry[at+1:] == "" {
+			continue
+		}
+
+		auths = append(auths, RegistryAuth{
+			Server:   entry[at+1:],
+			Username: username,
+			Password: password,
+		})
+	}
+	return auths
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (c *Config) getEnvInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s must be an integer, got %q", key, raw))
+		return fallback
+	}
+	return value
+}
+
+func getEnvList(key string, fallback []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	values := make([]string, 0)
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (c *Config) getEnvBool(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s must be true or false, got %q", key, raw))
+		return fallback
+	}
+	return value
+}
+
+func (c *Config) getEnvDuration(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s must be a duration such as 30s, got %q", key, raw))
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// restartOnly lists the settings that are read once at startup (listeners,
+// storage locations, the router layout) and therefore cannot be changed
+// by a reload.
+var restartOnly = map[string]bool{
+	"server_port":         true,
+	"db_path":             true,
+	"base_dir":            true,
+	"docker_network":      true,
+	"port_base":           true,
+	"proxy_enabled":       true,
+	"proxy_mode":          true,
+	"publish_ports":       true,
+	"tls_cert_file":       true,
+	"tls_key_file":        true,
+	"tls_client_ca_file":  true,
+	"tls_client_auth":     true,
+	"tls_reload_interval": true,
+	"acme_enabled":        true,
+	"acme_domains":        true,
+	"acme_email":          true,
+	"acme_directory_url":  true,
+	"acme_cache_dir":      true,
+	"acme_ca_root":        true,
+	"acme_http_addr":      true,
+}
+
+// Store holds the active configuration. Components call Get whenever
+// they need a setting so that reloaded values take effect without a
+// restart.
+type Store struct {
+	current atomic.Pointer[Config]
+	mu      sync.Mutex
+}
+
+// ReloadResult lists the settings a reload changed and the changes that
+// were ignored because they need a restart.
+type ReloadResult struct {
+	Applied []string `json:"applied"`
+	Ignored []string `json:"ignored"`
+}
+
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.current.Store(cfg)
+	return s
+}
+
+func (s *Store) Get() *Config {
+	return s.current.Load()
+}
+
+// Reload reads the configuration again from the same file and environment.
+// The new settings are validated as a whole before anything is applied.
+func (s *Store) Reload() (*ReloadResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.Get()
+	next, err := readConfig(old.File)
+	if err != nil {
+		return nil, err
+	}
+
+	// The database path is derived from base_dir, which cannot change
+	// without a restart either.
+	if next.DBPath == "" {
+		next.DBPath = old.DBPath
+	}
+
+	oldValue := reflect.ValueOf(old).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	var ignored []string
+	for i := 0; i < nextValue.NumField(); i++ {
+		name := yamlName(nextValue.Type().Field(i))
+		if !restartOnly[name] {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			nextValue.Field(i).Set(oldValue.Field(i))
+			ignored = append(ignored, name)
+		}
+	}
+
+	next.applyDerivedDefaults()
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Applied: []string{}, Ignored: append([]string{}, ignored...)}
+	for i := 0; i < nextValue.NumField(); i++ {
+		name := yamlName(nextValue.Type().Field(i))
+		if name == "" || restartOnly[name] {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			result.Applied = append(result.Applied, name)
+		}
+	}
+
+	s.current.Store(next)
+	return result, nil
+}
+
+// Redacted returns the settings keyed by their config file names, with
+// secrets masked and durations rendered the way they are written.
+func (c *Config) Redacted() map[string]interface{} {
+	copied := *c
+	copied.APIKeys = make([]APIKey, len(c.APIKeys))
+	for i, key := range c.APIKeys {
+		key.Key = redact(key.Key)
+		copied.APIKeys[i] = key
+	}
+	copied.RegistryAuth = make([]RegistryAuth, len(c.RegistryAuth))
+	for i, auth := range c.RegistryAuth {
+		auth.Password = redact(auth.Password)
+		copied.RegistryAuth[i] = auth
+	}
+
+	settings := make(map[string]interface{})
+	value := reflect.ValueOf(copied)
+	for i := 0; i < value.NumField(); i++ {
+		name := yamlName(value.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		field := value.Field(i).Interface()
+		if d, ok := field.(time.Duration); ok {
+			field = d.String()
+		}
+		settings[name] = field
+	}
+	if c.File != "" {
+		settings["file"] = c.File
+	}
+	return settings
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+func yamlName(field reflect.StructField) string {
+	tag := field.Tag.Get("yaml")
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+func (r *ReloadResult) String() string {
+	return fmt.Sprintf("applied %v, ignored until restart %v", r.Applied, r.Ignored)
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// Validate checks the settings for values the manager cannot run with.
+// Every problem is reported at once so a broken config file can be fixed
+// in one pass.
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	problems = append(problems, c.envErrors...)
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+		addf("server_port must be a port number, got %q", c.ServerPort)
+	}
+	if c.BaseDir == "" {
+		addf("base_dir is required")
+	}
+	if c.PortBase < 1024 || c.PortBase > 65535 {
+		addf("port_base must be between 1024 and 65535, got %d", c.PortBase)
+	}
+	if c.DockerImage == "" {
+		addf("docker_image is required")
+	} else if _, err := reference.ParseNormalizedNamed(c.DockerImage); err != nil {
+		addf("docker_image is not a valid image reference: %v", err)
+	}
+	for _, auth := range c.RegistryAuth {
+		if auth.Server == "" || auth.Username == "" {
+			addf("registry_auth entries need a server and a username")
+		}
+	}
+	if c.ImageLoadMaxMB < 1 {
+		addf("image_load_max_mb must be positive, got %d", c.ImageLoadMaxMB)
+	}
+	if c.DockerNetwork == "" {
+		addf("docker_network is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range c.APIKeys {
+		if key.Name == "" || key.Key == "" {
+			addf("api_keys entries need a name and a key")
+			continue
+		}
+		if key.Role != "admin" && key.Role != "operator" {
+			addf("api key %q has unknown role %q", key.Name, key.Role)
+		}
+		if seen[key.Key] {
+			addf("api key %q reuses the key of another entry", key.Name)
+		}
+		seen[key.Key] = true
+	}
+
+	for name, d := range map[string]time.Duration{
+		"stop_timeout":           c.StopTimeout,
+		"drain_timeout":          c.DrainTimeout,
+		"upgrade_health_timeout": c.UpgradeHealthTimeout,
+		"probe_interval":         c.ProbeInterval,
+		"probe_timeout":          c.ProbeTimeout,
+		"probe_retention":        c.ProbeRetention,
+		"recovery_interval":      c.RecoveryInterval,
+		"recovery_window":        c.RecoveryWindow,
+		"idle_check_interval":    c.IdleCheckInterval,
+		"wake_timeout":           c.WakeTimeout,
+		"orphan_gc_interval":     c.OrphanGCInterval,
+		"tls_reload_interval":    c.TLSReloadInterval,
+	} {
+		if d <= 0 {
+			addf("%s must be positive", name)
+		}
+	}
+	if c.LogWait < 0 {
+		addf("log_wait must not be negative")
+	}
+	if c.ProbeFailureThreshold < 1 {
+		addf("probe_failure_threshold must be at least 1")
+	}
+	if c.RecoveryRestartThreshold < 1 {
+		addf("recovery_restart_threshold must be at least 1")
+	}
+	if c.RecoveryMaxAttempts < 1 {
+		addf("recovery_max_attempts must be at least 1")
+	}
+	if c.TenantUIDBase < 0 || c.TenantUIDBase > 1<<31-1-65535 {
+		addf("tenant_uid_base must be between 0 and %d", 1<<31-1-65535)
+	}
+	for _, path := range c.TenantTmpfs {
+		if !strings.HasPrefix(path, "/") {
+			addf("tenant_tmpfs entries must be absolute paths, got %q", path)
+		}
+	}
+	if c.OrphanGCGracePeriod < 0 {
+		addf("orphan_gc_grace_period must not be negative")
+	}
+	if c.IdleTrafficThreshold < 0 {
+		addf("idle_traffic_threshold must not be negative")
+	}
+
+	checkOneOf := func(name, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		addf("%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value)
+	}
+	checkOneOf("image_pull_policy", c.ImagePullPolicy, "if-missing", "always", "never")
+	checkOneOf("probe_target", c.ProbeTarget, "host", "network")
+	checkOneOf("recovery_policy", c.RecoveryPolicy, "restart", "recreate", "quarantine")
+	checkOneOf("tenant_egress", c.TenantEgress, "allow", "deny")
+	checkOneOf("orphan_gc_policy", c.OrphanGCPolicy, "report", "delete")
+	checkOneOf("proxy_mode", c.ProxyMode, "path", "host", "both")
+	checkOneOf("prometheus_target_mode", c.PrometheusTargetMode, "internal", "public")
+	checkOneOf("tls_client_auth", c.TLSClientAuth, "request", "require")
+	checkOneOf("tls_client_role", c.TLSClientRole, "admin", "operator")
+
+	if c.TenantEgress == "deny" && !c.NetworkIsolation {
+		addf("tenant_egress deny requires network_isolation")
+	}
+	if c.TenantEgress == "deny" && c.PublishPorts {
+		addf("tenant_egress deny needs publish_ports disabled, internal networks cannot publish ports")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		addf("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.ACMEEnabled && len(c.ACMEDomains) == 0 {
+		addf("acme_domains is required when acme_enabled is set")
+	}
+	if c.TLSClientAuth == "require" && c.TLSClientCAFile == "" {
+		addf("tls_client_ca_file is required when tls_client_auth is \"require\"")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		addf("cors.allowed_origins must list at least one origin")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultApp is the template of tenants created before the catalog
+// existed and of requests that do not name an app.
+const DefaultApp = "filebrowser"
+
+// App is a catalog template for tenant containers. Templates cannot be
+// changed once created, because existing tenants keep relying on the port,
+// mounts and health path they were created with.
+type App struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string `gorm:"not null;default:''"`
+	// Image is empty for templates that follow docker_image.
+	Image        string `gorm:"not null;default:''"`
+	InternalPort int    `gorm:"not null"`
+	Mounts       string `gorm:"type:text;not null"`
+	Env          string `gorm:"type:text;not null"`
+	HealthPath   string `gorm:"not null;default:''"`
+	// BaseURLEnv receives the tenant's path prefix when the proxy serves
+	// tenants under /t/<name>/.
+	BaseURLEnv string `gorm:"not null;default:''"`
+	// CredentialRegex extracts the initial password from the container
+	// logs with its first capture group; Username is reported alongside.
+	CredentialRegex string `gorm:"not null;default:''"`
+	Username        string `gorm:"not null;default:''"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// AppMount binds one tenant resource into the container. Source is
+// "files" or "config" for the tenant directories, or "volume" for the
+// tenant's named settings volume.
+type AppMount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+func (a *App) MountList() []AppMount {
+	mounts := make([]AppMount, 0)
+	if a.Mounts != "" {
+		json.Unmarshal([]byte(a.Mounts), &mounts)
+	}
+	return mounts
+}
+
+func (a *App) EnvList() []string {
+	env := make([]string, 0)
+	if a.Env != "" {
+		json.Unmarshal([]byte(a.Env), &env)
+	}
+	return env
+}
+
+func (a *App) SetMounts(mounts []AppMount) {
+	data, _ := json.Marshal(mounts)
+	a.Mounts = string(data)
+}
+
+func (a *App) SetEnv(env []string) {
+	data, _ := json.Marshal(env)
+	a.Env = string(data)
+}
+
+// seedApps adds the FileBrowser template that used to be hardcoded.
+func seedApps() error {
+	var count int64
+	if err := DB.Model(&App{}).Where("name = ?", DefaultApp).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to query apps: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	app := &App{
+		Name:            DefaultApp,
+		Description:     "FileBrowser web file manager",
+		InternalPort:    80,
+		HealthPath:      "/health",
+		BaseURLEnv:      "FB_BASEURL",
+		CredentialRegex: `generated password:\s+(\S+)`,
+		Username:        "admin",
+	}
+	app.SetMounts([]AppMount{
+		{Source: "files", Target: "/srv"},
+		{Source: "volume", Target: "/database"},
+		{Source: "config", Target: "/config"},
+	})
+	app.SetEnv([]string{})
+
+	return CreateApp(app)
+}
+
+func CreateApp(app *App) error {
+	if err := DB.Create(app).Error; err != nil {
+		return fmt.Errorf("failed to insert app: %w", err)
+	}
+	return nil
+}
+
+func GetAppByName(name string) (*App, error) {
+	var app App
+	result := DB.Where("name = ?", name).First(&app)
+
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("app %s not found", name)
+		}
+		return nil, fmt.Errorf("failed to query app: %w", result.Error)
+	}
+
+	return &app, nil
+}
+
+func GetAllApps() ([]App, error) {
+	var apps []App
+	if err := DB.Order("name ASC").Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("failed to query apps: %w", err)
+	}
+	return apps, nil
+}
+
+func CountTenantsByApp(name string) (int, error) {
+	var count int64
+	if err := DB.Model(&Tenant{}).Where("app = ?", name).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count tenants: %w", err)
+	}
+	return int(count), nil
+}
+
+func DeleteApp(name string) error {
+	result := DB.Where("name = ?", name).Delete(&App{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete app: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("app %s not found", name)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+type AuditLog struct {
+	ID         uint   `gorm:"primaryKey"`
+	Actor      string `gorm:"index;not null"`
+	Role       string `gorm:"not null;default:''"`
+	Action     string `gorm:"index;not null"`
+	TenantName string `gorm:"index"`
+	Params     string `gorm:"type:text"`
+	Outcome    string `gorm:"index;not null"`
+	StatusCode int    `gorm:"not null"`
+	Error      string `gorm:"type:text"`
+	ClientIP   string
+	StartedAt  time.Time `gorm:"index;not null"`
+	FinishedAt time.Time `gorm:"not null"`
+}
+
+// Audit entries are append-only; GORM hooks reject any attempt to modify
+// or remove them through the ORM.
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return fmt.Errorf("audit log is append-only")
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return fmt.Errorf("audit log is append-only")
+}
+
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TenantName string
+	Outcome    string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+func (f AuditFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Actor != "" {
+		query = query.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.TenantName != "" {
+		query = query.Where("tenant_name = ?", f.TenantName)
+	}
+	if f.Outcome != "" {
+		query = query.Where("outcome = ?", f.Outcome)
+	}
+	if f.Since != nil {
+		query = query.Where("started_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("started_at <= ?", *f.Until)
+	}
+	return query
+}
+
+func CreateAuditLog(entry *AuditLog) error {
+	result := DB.Create(entry)
+	if result.Error != nil {
+		return fmt.Errorf("failed to insert audit log: %w", result.Error)
+	}
+	return nil
+}
+
+func GetAuditLogs(filter AuditFilter, page, perPage int) ([]AuditLog, int, error) {
+	var entries []AuditLog
+	var total int64
+
+	if err := filter.apply(DB.Model(&AuditLog{})).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	offset := (page - 1) * perPage
+
+	result := filter.apply(DB.Model(&AuditLog{})).
+		Order("started_at DESC, id DESC").
+		Limit(perPage).
+		Offset(offset).
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to query audit logs: %w", result.Error)
+	}
+
+	return entries, int(total), nil
+}
+
+// EachAuditLog streams matching entries in chronological order without
+// loading the whole table into memory.
+func EachAuditLog(filter AuditFilter, fn func(entry AuditLog) error) error {
+	var batch []AuditLog
+	result := filter.apply(DB.Model(&AuditLog{})).
+		Order("id ASC").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to export audit logs: %w", result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Campaign moves a selection of tenants to one image in waves. Wave 0 is
+// the canary batch.
+type Campaign struct {
+	ID uint `gorm:"primaryKey"`
+	// Image is empty to move every tenant to the image of its app.
+	Image       string `gorm:"not null"`
+	Selector    string `gorm:"type:text"`
+	State       string `gorm:"index;not null"`
+	CanarySize  int    `gorm:"not null"`
+	WaveSize    int    `gorm:"not null"`
+	MaxFailures int    `gorm:"not null"`
+	// HealthGate is how long upgraded tenants of a wave must stay healthy
+	// before the next wave starts.
+	HealthGate time.Duration `gorm:"not null"`
+	Wave       int           `gorm:"not null;default:0"`
+	Waves      int           `gorm:"not null"`
+	Error      string        `gorm:"type:text"`
+	CreatedBy  string        `gorm:"not null;default:''"`
+
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	FinishedAt *time.Time
+}
+
+type CampaignTenant struct {
+	ID            uint   `gorm:"primaryKey"`
+	CampaignID    uint   `gorm:"index;not null"`
+	TenantName    string `gorm:"index;not null"`
+	Wave          int    `gorm:"not null"`
+	State         string `gorm:"not null"`
+	PreviousImage string `gorm:"not null;default:''"`
+	ImageDigest   string `gorm:"not null;default:''"`
+	Error         string `gorm:"type:text"`
+
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	FinishedAt *time.Time
+}
+
+// CreateCampaign stores the campaign together with its tenants.
+func CreateCampaign(campaign *Campaign, tenants []CampaignTenant) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return fmt.Errorf("failed to insert campaign: %w", err)
+		}
+		for i := range tenants {
+			tenants[i].CampaignID = campaign.ID
+		}
+		if len(tenants) > 0 {
+			if err := tx.Create(&tenants).Error; err != nil {
+				return fmt.Errorf("failed to insert campaign tenants: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func GetCampaignByID(id uint) (*Campaign, error) {
+	var campaign Campaign
+	result := DB.First(&campaign, id)
+
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("campaign not found")
+		}
+		return nil, fmt.Errorf("failed to query campaign: %w", result.Error)
+	}
+
+	return &campaign, nil
+}
+
+func GetAllCampaigns() ([]Campaign, error) {
+	var campaigns []Campaign
+	if err := DB.Order("id DESC").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+func GetCampaignsByState(state string) ([]Campaign, error) {
+	var campaigns []Campaign
+	if err := DB.Where("state = ?", state).Order("id ASC").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+func UpdateCampaignFields(id uint, fields map[string]interface{}) error {
+	if err := DB.Model(&Campaign{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	return nil
+}
+
+// TransitionCampaign moves the campaign to state when it is currently in
+// one of from, and reports whether it did.
+func TransitionCampaign(id uint, from []string, state string) (bool, error) {
+	result := DB.Model(&Campaign{}).
+		Where("id = ? AND state IN ?", id, from).
+		Update("state", state)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update campaign state: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func GetCampaignTenants(campaignID uint) ([]CampaignTenant, error) {
+	var tenants []CampaignTenant
+	if err := DB.Where("campaign_id = ?", campaignID).Order("wave ASC, id ASC").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query campaign tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func UpdateCampaignTenant(tenant *CampaignTenant, fields map[string]interface{}) error {
+	if err := DB.Model(tenant).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update campaign tenant: %w", err)
+	}
+	return nil
+}
+
+// SkipCampaignTenants marks every tenant still waiting in the campaign.
+func SkipCampaignTenants(campaignID uint, pending, skipped, reason string) error {
+	result := DB.Model(&CampaignTenant{}).
+		Where("campaign_id = ? AND state = ?", campaignID, pending).
+		Updates(map[string]interface{}{"state": skipped, "error": reason, "finished_at": time.Now()})
+	if result.Error != nil {
+		return fmt.Errorf("failed to skip campaign tenants: %w", result.Error)
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -13,21 +15,78 @@ import (
 var DB *gorm.DB
 
 type Tenant struct {
-	ID            uint      `gorm:"primaryKey"`
-	Name          string    `gorm:"uniqueIndex;not null"`
-	Port          int       `gorm:"uniqueIndex;not null"`
-	ContainerName string    `gorm:"not null"`
-	VolumeName    string    `gorm:"not null"`
-	Status        string    `gorm:"not null"`
-	CreatedAt     time.Time `gorm:"autoCreateTime"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	ID            uint   `gorm:"primaryKey"`
+	Name          string `gorm:"uniqueIndex;not null"`
+	Port          int    `gorm:"uniqueIndex;not null"`
+	ContainerName string `gorm:"not null"`
+	VolumeName    string `gorm:"not null"`
+	Status        string `gorm:"not null"`
+	// App names the catalog template the container is built from.
+	App string `gorm:"index;not null;default:'filebrowser'"`
+
+	Description string `gorm:"type:text;not null;default:''"`
+
+	IdleTimeoutMinutes int `gorm:"not null;default:0"`
+	LastActivityAt     *time.Time
+
+	CustomDomain *string `gorm:"uniqueIndex"`
+
+	// Network is empty for tenants on the shared network.
+	Network string `gorm:"not null;default:''"`
+	Egress  string `gorm:"not null;default:'allow'"`
+
+	// Image is the reference the tenant was created from and ImageDigest
+	// the exact image it resolved to. Both are empty for tenants created
+	// before images were pinned, which follow docker_image.
+	Image       string `gorm:"not null;default:''"`
+	ImageDigest string `gorm:"not null;default:''"`
+
+	// Security profile the container was created with. Tenants created
+	// before hardening have RunAsUID 0 and run as root.
+	RunAsUID        int    `gorm:"not null;default:0"`
+	RunAsGID        int    `gorm:"not null;default:0"`
+	CapDrop         string `gorm:"not null;default:''"`
+	NoNewPrivileges bool   `gorm:"not null;default:false"`
+	ReadOnlyRootfs  bool   `gorm:"not null;default:false"`
+	SeccompProfile  string `gorm:"not null;default:''"`
+	AppArmorProfile string `gorm:"not null;default:''"`
+
+	// Resource limits of the container; zero means unlimited.
+	MemoryMB      int     `gorm:"not null;default:0"`
+	CPUs          float64 `gorm:"not null;default:0"`
+	RestartPolicy string  `gorm:"not null;default:'unless-stopped'"`
+	// Env is a JSON object of variables set on top of the app's.
+	Env string `gorm:"type:text;not null;default:''"`
+
+	// ClonedFrom names the tenant this one was copied from.
+	ClonedFrom string `gorm:"index;not null;default:''"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (t *Tenant) EnvMap() map[string]string {
+	env := make(map[string]string)
+	if t.Env != "" {
+		json.Unmarshal([]byte(t.Env), &env)
+	}
+	return env
+}
+
+func (t *Tenant) SetEnv(env map[string]string) {
+	if len(env) == 0 {
+		t.Env = ""
+		return
+	}
+	data, _ := json.Marshal(env)
+	t.Env = string(data)
 }
 
 func InitDB(dbPath string) error {
 	var err error
-	
+
 	gormLogger := logger.Default.LogMode(logger.Silent)
-	
+
 	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
 		Logger: gormLogger,
 	})
@@ -44,10 +103,14 @@ func InitDB(dbPath string) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	if err := DB.AutoMigrate(&Tenant{}); err != nil {
+	if err := DB.AutoMigrate(&Tenant{}, &AuditLog{}, &Webhook{}, &WebhookDelivery{}, &HealthCheck{}, &TenantEvent{}, &Operation{}, &Campaign{}, &CampaignTenant{}, &App{}, &TenantLabel{}); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := seedApps(); err != nil {
+		return err
+	}
+
 	log.Println("Database initialized successfully with GORM")
 	return nil
 }
@@ -63,7 +126,21 @@ func CreateTenant(tenant *Tenant) error {
 func GetTenantByName(name string) (*Tenant, error) {
 	var tenant Tenant
 	result := DB.Where("name = ?", name).First(&tenant)
-	
+
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("tenant not found")
+		}
+		return nil, fmt.Errorf("failed to query tenant: %w", result.Error)
+	}
+
+	return &tenant, nil
+}
+
+func GetTenantByCustomDomain(domain string) (*Tenant, error) {
+	var tenant Tenant
+	result := DB.Where("custom_domain = ?", strings.ToLower(domain)).First(&tenant)
+
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("tenant not found")
@@ -74,19 +151,94 @@ func GetTenantByName(name string) (*Tenant, error) {
 	return &tenant, nil
 }
 
-func GetAllTenants(page, perPage int) ([]Tenant, int, error) {
+// TenantFilter narrows the tenant list. The zero value matches every
+// tenant.
+type TenantFilter struct {
+	Labels []LabelRequirement
+	// Query matches a substring of the tenant name.
+	Query         string
+	Statuses      []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// TenantPage orders and slices the tenant list. Rows are always ordered by
+// ID after the sort column so that pages are stable. When After is set the
+// page starts after that position instead of at Offset.
+type TenantPage struct {
+	Sort   string
+	Desc   bool
+	Offset int
+	Limit  int
+	After  *TenantKey
+}
+
+// TenantKey is a position in the tenant list: the value of the sort column
+// and the ID of the tenant.
+type TenantKey struct {
+	Value interface{}
+	ID    uint
+}
+
+// tenantSortColumns maps the sort names of the API to SQL expressions.
+// Tenants that were never active count as used when they were created.
+var tenantSortColumns = map[string]string{
+	"name":       "name",
+	"port":       "port",
+	"created_at": "created_at",
+	"status":     "status",
+	"usage":      "COALESCE(last_activity_at, created_at)",
+}
+
+func (f TenantFilter) apply(query *gorm.DB) *gorm.DB {
+	query = whereLabels(query, f.Labels)
+	if f.Query != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Query)
+		query = query.Where(`name LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+	}
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if f.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *f.CreatedBefore)
+	}
+	return query
+}
+
+func GetAllTenants(filter TenantFilter, page TenantPage) ([]Tenant, int, error) {
 	var tenants []Tenant
 	var total int64
 
-	if err := DB.Model(&Tenant{}).Count(&total).Error; err != nil {
+	column, ok := tenantSortColumns[page.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort field %q", page.Sort)
+	}
+
+	query := filter.apply(DB.Model(&Tenant{}))
+
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count tenants: %w", err)
 	}
 
-	offset := (page - 1) * perPage
+	direction, compare := "ASC", ">"
+	if page.Desc {
+		direction, compare = "DESC", "<"
+	}
 
-	result := DB.Order("created_at DESC").
-		Limit(perPage).
-		Offset(offset).
+	if page.After != nil {
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", column, compare),
+			page.After.Value, page.After.Value, page.After.ID,
+		)
+	} else {
+		query = query.Offset(page.Offset)
+	}
+
+	result := query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(page.Limit).
 		Find(&tenants)
 
 	if result.Error != nil {
@@ -96,6 +248,22 @@ func GetAllTenants(page, perPage int) ([]Tenant, int, error) {
 	return tenants, int(total), nil
 }
 
+func ListAllTenants() ([]Tenant, error) {
+	var tenants []Tenant
+	if err := DB.Order("name ASC").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func GetTenantsByStatus(statuses ...string) ([]Tenant, error) {
+	var tenants []Tenant
+	if err := DB.Where("status IN ?", statuses).Order("name ASC").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	return tenants, nil
+}
+
 func UpdateTenantStatus(name, status string) error {
 	result := DB.Model(&Tenant{}).
 		Where("name = ?", name).
@@ -112,11 +280,28 @@ func UpdateTenantStatus(name, status string) error {
 	return nil
 }
 
-func DeleteTenant(name string) error {
-	result := DB.Where("name = ?", name).Delete(&Tenant{})
+// SwapTenantStatus moves a tenant from one status to another and reports
+// whether it did, so a caller acting on a status it read earlier does not
+// overwrite a change made since.
+func SwapTenantStatus(name, from, to string) (bool, error) {
+	result := DB.Model(&Tenant{}).
+		Where("name = ? AND status = ?", name, from).
+		Update("status", to)
 
 	if result.Error != nil {
-		return fmt.Errorf("failed to delete tenant: %w", result.Error)
+		return false, fmt.Errorf("failed to update tenant status: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func UpdateTenantFields(name string, fields map[string]interface{}) error {
+	result := DB.Model(&Tenant{}).
+		Where("name = ?", name).
+		Updates(fields)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to update tenant: %w", result.Error)
 	}
 
 	if result.RowsAffected == 0 {
@@ -126,7 +311,69 @@ func DeleteTenant(name string) error {
 	return nil
 }
 
-func GetNextAvailablePort() (int, error) {
+func DeleteTenant(name string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ?", name).Delete(&Tenant{})
+
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete tenant: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("tenant not found")
+		}
+
+		if err := tx.Where("tenant_name = ?", name).Delete(&TenantLabel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete tenant labels: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RenameTenant moves the tenant row and everything keyed by its name to
+// the new name in one transaction. The audit log keeps the old name.
+func RenameTenant(name, newName string, fields map[string]interface{}) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		fields["name"] = newName
+		result := tx.Model(&Tenant{}).Where("name = ?", name).Updates(fields)
+		if result.Error != nil {
+			return fmt.Errorf("failed to rename tenant: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("tenant not found")
+		}
+
+		for _, model := range []interface{}{&TenantLabel{}, &HealthCheck{}, &TenantEvent{}, &CampaignTenant{}} {
+			if err := tx.Model(model).Where("tenant_name = ?", name).Update("tenant_name", newName).Error; err != nil {
+				return fmt.Errorf("failed to rename tenant: %w", err)
+			}
+		}
+		if err := tx.Model(&Tenant{}).Where("cloned_from = ?", name).Update("cloned_from", newName).Error; err != nil {
+			return fmt.Errorf("failed to rename tenant: %w", err)
+		}
+		return nil
+	})
+}
+
+// CreateTenantWithLabels inserts the tenant and its labels together.
+func CreateTenantWithLabels(tenant *Tenant, labels map[string]string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return fmt.Errorf("failed to insert tenant: %w", err)
+		}
+
+		values := make(map[string]*string, len(labels))
+		for key, value := range labels {
+			values[key] = &value
+		}
+		return updateTenantLabels(tx, tenant.Name, values)
+	})
+}
+
+// GetNextAvailablePort returns the port after the highest one in use,
+// counting ports reserved by provisioning that has not finished yet.
+func GetNextAvailablePort(base int) (int, error) {
 	var tenant Tenant
 	result := DB.Order("port DESC").Limit(1).Find(&tenant)
 
@@ -134,11 +381,21 @@ func GetNextAvailablePort() (int, error) {
 		return 0, fmt.Errorf("failed to get last port: %w", result.Error)
 	}
 
-	if result.RowsAffected == 0 {
-		return 9000, nil
+	var op Operation
+	reserved := DB.Where("state = ?", OperationRunning).Order("port DESC").Limit(1).Find(&op)
+	if reserved.Error != nil {
+		return 0, fmt.Errorf("failed to get reserved ports: %w", reserved.Error)
+	}
+
+	highest := tenant.Port
+	if op.Port > highest {
+		highest = op.Port
+	}
+	if highest < base {
+		return base, nil
 	}
 
-	return tenant.Port + 1, nil
+	return highest + 1, nil
 }
 
 func CloseDB() error {
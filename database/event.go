@@ -0,0 +1,53 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+type TenantEvent struct {
+	ID         uint      `gorm:"primaryKey"`
+	TenantName string    `gorm:"index;not null"`
+	Type       string    `gorm:"index;not null"`
+	Message    string    `gorm:"not null"`
+	Data       string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"index;autoCreateTime"`
+}
+
+func CreateTenantEvent(event *TenantEvent) error {
+	result := DB.Create(event)
+	if result.Error != nil {
+		return fmt.Errorf("failed to insert tenant event: %w", result.Error)
+	}
+	return nil
+}
+
+func GetTenantEvents(tenantName string, page, perPage int) ([]TenantEvent, int, error) {
+	var events []TenantEvent
+	var total int64
+
+	if err := DB.Model(&TenantEvent{}).Where("tenant_name = ?", tenantName).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count tenant events: %w", err)
+	}
+
+	offset := (page - 1) * perPage
+
+	result := DB.Where("tenant_name = ?", tenantName).
+		Order("id DESC").
+		Limit(perPage).
+		Offset(offset).
+		Find(&events)
+
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to query tenant events: %w", result.Error)
+	}
+
+	return events, int(total), nil
+}
+
+func DeleteTenantEvents(tenantName string) error {
+	if err := DB.Where("tenant_name = ?", tenantName).Delete(&TenantEvent{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tenant events: %w", err)
+	}
+	return nil
+}
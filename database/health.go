@@ -0,0 +1,98 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type HealthCheck struct {
+	ID         uint      `gorm:"primaryKey"`
+	TenantName string    `gorm:"index:idx_health_tenant_checked;not null"`
+	Success    bool      `gorm:"not null"`
+	StatusCode int       `gorm:"not null;default:0"`
+	LatencyMs  int64     `gorm:"not null;default:0"`
+	Error      string    `gorm:"type:text"`
+	CheckedAt  time.Time `gorm:"index:idx_health_tenant_checked;not null"`
+}
+
+func CreateHealthCheck(check *HealthCheck) error {
+	result := DB.Create(check)
+	if result.Error != nil {
+		return fmt.Errorf("failed to insert health check: %w", result.Error)
+	}
+	return nil
+}
+
+// GetHealthCheckCounts returns the total and successful probe counts for a
+// tenant since the given time.
+func GetHealthCheckCounts(tenantName string, since time.Time) (int, int, error) {
+	var counts struct {
+		Total     int64
+		Successes int64
+	}
+
+	result := DB.Model(&HealthCheck{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS successes").
+		Where("tenant_name = ? AND checked_at >= ?", tenantName, since).
+		Scan(&counts)
+
+	if result.Error != nil {
+		return 0, 0, fmt.Errorf("failed to count health checks: %w", result.Error)
+	}
+
+	return int(counts.Total), int(counts.Successes), nil
+}
+
+func GetLastHealthCheck(tenantName string) (*HealthCheck, error) {
+	return lastHealthCheck(DB.Where("tenant_name = ?", tenantName))
+}
+
+func GetLastHealthCheckByResult(tenantName string, success bool) (*HealthCheck, error) {
+	return lastHealthCheck(DB.Where("tenant_name = ? AND success = ?", tenantName, success))
+}
+
+func lastHealthCheck(query *gorm.DB) (*HealthCheck, error) {
+	var check HealthCheck
+	result := query.Order("checked_at DESC, id DESC").Limit(1).Find(&check)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query health check: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &check, nil
+}
+
+// CountFailuresSince counts failed probes recorded after the given time,
+// which is the consecutive failure streak when passed the last success.
+func CountFailuresSince(tenantName string, since time.Time) (int, error) {
+	var count int64
+	result := DB.Model(&HealthCheck{}).
+		Where("tenant_name = ? AND success = ? AND checked_at > ?", tenantName, false, since).
+		Count(&count)
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to count failed health checks: %w", result.Error)
+	}
+
+	return int(count), nil
+}
+
+func PruneHealthChecks(before time.Time) error {
+	if err := DB.Where("checked_at < ?", before).Delete(&HealthCheck{}).Error; err != nil {
+		return fmt.Errorf("failed to prune health checks: %w", err)
+	}
+	return nil
+}
+
+func DeleteHealthChecks(tenantName string) error {
+	if err := DB.Where("tenant_name = ?", tenantName).Delete(&HealthCheck{}).Error; err != nil {
+		return fmt.Errorf("failed to delete health checks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TenantLabel is one key/value label of a tenant. Labels are kept in their
+// own table so selectors can use the (key, value) index.
+type TenantLabel struct {
+	ID         uint   `gorm:"primaryKey"`
+	TenantName string `gorm:"uniqueIndex:idx_tenant_label;not null"`
+	Key        string `gorm:"uniqueIndex:idx_tenant_label;index:idx_label_key_value;not null"`
+	Value      string `gorm:"index:idx_label_key_value;not null"`
+}
+
+const (
+	LabelEquals    = "="
+	LabelNotEquals = "!="
+	LabelExists    = "exists"
+)
+
+// LabelRequirement is one term of a label selector.
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+func GetTenantLabels(name string) (map[string]string, error) {
+	labels, err := GetLabelsForTenants([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	return labels[name], nil
+}
+
+// GetLabelsForTenants returns the labels of every listed tenant that has
+// any, keyed by tenant name.
+func GetLabelsForTenants(names []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	var rows []TenantLabel
+	if err := DB.Where("tenant_name IN ?", names).Order("key ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query tenant labels: %w", err)
+	}
+
+	for _, row := range rows {
+		if result[row.TenantName] == nil {
+			result[row.TenantName] = make(map[string]string)
+		}
+		result[row.TenantName][row.Key] = row.Value
+	}
+	return result, nil
+}
+
+// UpdateTenantLabels sets the labels with a value and removes those
+// mapped to nil.
+func UpdateTenantLabels(name string, labels map[string]*string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return updateTenantLabels(tx, name, labels)
+	})
+}
+
+// UpdateTenantWithLabels changes tenant fields and labels together.
+func UpdateTenantWithLabels(name string, fields map[string]interface{}, labels map[string]*string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if len(fields) > 0 {
+			if err := tx.Model(&Tenant{}).Where("name = ?", name).Updates(fields).Error; err != nil {
+				return fmt.Errorf("failed to update tenant: %w", err)
+			}
+		}
+		return updateTenantLabels(tx, name, labels)
+	})
+}
+
+func updateTenantLabels(tx *gorm.DB, name string, labels map[string]*string) error {
+	for key, value := range labels {
+		if err := tx.Where("tenant_name = ? AND key = ?", name, key).Delete(&TenantLabel{}).Error; err != nil {
+			return fmt.Errorf("failed to remove tenant label: %w", err)
+		}
+		if value == nil {
+			continue
+		}
+		if err := tx.Create(&TenantLabel{TenantName: name, Key: key, Value: *value}).Error; err != nil {
+			return fmt.Errorf("failed to insert tenant label: %w", err)
+		}
+	}
+	return nil
+}
+
+// whereLabels narrows a tenant query to the tenants matching every
+// requirement.
+func whereLabels(query *gorm.DB, requirements []LabelRequirement) *gorm.DB {
+	for _, req := range requirements {
+		switch req.Operator {
+		case LabelEquals:
+			query = query.Where("name IN (?)", DB.Model(&TenantLabel{}).Select("tenant_name").Where("key = ? AND value = ?", req.Key, req.Value))
+		case LabelNotEquals:
+			query = query.Where("name NOT IN (?)", DB.Model(&TenantLabel{}).Select("tenant_name").Where("key = ? AND value = ?", req.Key, req.Value))
+		case LabelExists:
+			query = query.Where("name IN (?)", DB.Model(&TenantLabel{}).Select("tenant_name").Where("key = ?", req.Key))
+		}
+	}
+	return query
+}
+
+// GetTenantNamesByLabels returns the names of the tenants matching every
+// requirement.
+func GetTenantNamesByLabels(requirements []LabelRequirement) ([]string, error) {
+	var names []string
+	query := whereLabels(DB.Model(&Tenant{}), requirements)
+	if err := query.Order("name ASC").Pluck("name", &names).Error; err != nil {
+		return nil, fmt.Errorf("failed to query tenants by label: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	OperationRunning     = "running"
+	OperationCompleted   = "completed"
+	OperationCompensated = "compensated"
+)
+
+// Operation journals a multi-step tenant operation. Step is the last step
+// that finished, so an operation interrupted by a crash can be resumed or
+// undone on the next start.
+type Operation struct {
+	ID            uint   `gorm:"primaryKey"`
+	Kind          string `gorm:"index;not null"`
+	TenantName    string `gorm:"index;not null"`
+	State         string `gorm:"index;not null"`
+	Step          string `gorm:"not null"`
+	Port          int    `gorm:"not null"`
+	ContainerName string `gorm:"not null"`
+	VolumeName    string `gorm:"not null"`
+	TenantDir     string `gorm:"not null"`
+	Network       string `gorm:"not null;default:''"`
+	// Upgrades record the images on both sides and the settings snapshot
+	// so an interrupted upgrade can be rolled back.
+	Image         string `gorm:"not null;default:''"`
+	PreviousImage string `gorm:"not null;default:''"`
+	Snapshot      string `gorm:"not null;default:''"`
+	// Settings is the container configuration the operation moves the
+	// tenant to, for recreations that keep the image.
+	Settings string `gorm:"type:text;not null;default:''"`
+	// NewName is the name a rename moves the tenant to, and
+	// PreviousStatus the status to restore when it is undone.
+	NewName        string `gorm:"not null;default:''"`
+	PreviousStatus string `gorm:"not null;default:''"`
+	// SourceName is the tenant a clone copies from. It counts as busy
+	// until the clone finishes, and PreviousStatus holds its status.
+	SourceName string `gorm:"index;not null;default:''"`
+
+	Error string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func CreateOperation(op *Operation) error {
+	if op.State == "" {
+		op.State = OperationRunning
+	}
+	if err := DB.Create(op).Error; err != nil {
+		return fmt.Errorf("failed to insert operation: %w", err)
+	}
+	return nil
+}
+
+func UpdateOperationStep(op *Operation, step string) error {
+	if err := DB.Model(op).Update("step", step).Error; err != nil {
+		return fmt.Errorf("failed to update operation step: %w", err)
+	}
+	op.Step = step
+	return nil
+}
+
+// FinishOperation moves the operation to a final state. A non-nil cause
+// is kept for later inspection.
+func FinishOperation(op *Operation, state string, cause error) error {
+	fields := map[string]interface{}{"state": state, "error": ""}
+	if cause != nil {
+		fields["error"] = cause.Error()
+	}
+	if err := DB.Model(op).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to finish operation: %w", err)
+	}
+	op.State = state
+	return nil
+}
+
+// RecordOperationError notes why an operation could not make progress
+// while leaving it pending.
+func RecordOperationError(op *Operation, cause error) error {
+	if err := DB.Model(op).Update("error", cause.Error()).Error; err != nil {
+		return fmt.Errorf("failed to record operation error: %w", err)
+	}
+	op.Error = cause.Error()
+	return nil
+}
+
+func GetPendingOperations() ([]Operation, error) {
+	var ops []Operation
+	if err := DB.Where("state = ?", OperationRunning).Order("id ASC").Find(&ops).Error; err != nil {
+		return nil, fmt.Errorf("failed to query pending operations: %w", err)
+	}
+	return ops, nil
+}
+
+// GetPendingOperation returns the latest unfinished operation involving a
+// tenant, including renames to its name and clones from it.
+func GetPendingOperation(tenantName string) (*Operation, error) {
+	var ops []Operation
+	result := DB.Where("(tenant_name = ? OR new_name = ? OR source_name = ?) AND state = ?", tenantName, tenantName, tenantName, OperationRunning).
+		Order("id DESC").
+		Limit(1).
+		Find(&ops)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query pending operation: %w", result.Error)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return &ops[0], nil
+}
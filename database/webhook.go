@@ -0,0 +1,167 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey"`
+	URL       string    `gorm:"not null"`
+	Events    string    `gorm:"not null"`
+	Secret    string    `gorm:"not null"`
+	Active    bool      `gorm:"not null;default:true"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// EventList returns the subscribed event types; "*" subscribes to all.
+func (w *Webhook) EventList() []string {
+	events := make([]string, 0)
+	for _, event := range strings.Split(w.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (w *Webhook) Subscribes(event string) bool {
+	events := w.EventList()
+	return slices.Contains(events, "*") || slices.Contains(events, event)
+}
+
+type WebhookDelivery struct {
+	ID         uint      `gorm:"primaryKey"`
+	WebhookID  uint      `gorm:"index;not null"`
+	EventID    string    `gorm:"index;not null"`
+	Event      string    `gorm:"not null"`
+	Payload    string    `gorm:"type:text"`
+	Attempt    int       `gorm:"not null"`
+	StatusCode int       `gorm:"not null;default:0"`
+	Success    bool      `gorm:"not null"`
+	Error      string    `gorm:"type:text"`
+	DurationMs int64     `gorm:"not null;default:0"`
+	CreatedAt  time.Time `gorm:"index;autoCreateTime"`
+
+	// NextAttemptAt is set on a failed attempt while its retry is still
+	// due, so that retries survive a restart.
+	NextAttemptAt *time.Time `gorm:"index"`
+}
+
+func CreateWebhook(webhook *Webhook) error {
+	result := DB.Create(webhook)
+	if result.Error != nil {
+		return fmt.Errorf("failed to insert webhook: %w", result.Error)
+	}
+	return nil
+}
+
+func GetWebhookByID(id uint) (*Webhook, error) {
+	var webhook Webhook
+	result := DB.First(&webhook, id)
+
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, fmt.Errorf("failed to query webhook: %w", result.Error)
+	}
+
+	return &webhook, nil
+}
+
+func GetAllWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := DB.Order("id ASC").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func GetActiveWebhooksForEvent(event string) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := DB.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+
+	matching := make([]Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event) {
+			matching = append(matching, webhook)
+		}
+	}
+	return matching, nil
+}
+
+func DeleteWebhook(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Webhook{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete webhook: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("webhook not found")
+		}
+
+		if err := tx.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+		return nil
+	})
+}
+
+func CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	result := DB.Create(delivery)
+	if result.Error != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", result.Error)
+	}
+	return nil
+}
+
+func GetWebhookDeliveries(webhookID uint, page, perPage int) ([]WebhookDelivery, int, error) {
+	var deliveries []WebhookDelivery
+	var total int64
+
+	query := DB.Model(&WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	offset := (page - 1) * perPage
+
+	result := DB.Where("webhook_id = ?", webhookID).
+		Order("id DESC").
+		Limit(perPage).
+		Offset(offset).
+		Find(&deliveries)
+
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to query webhook deliveries: %w", result.Error)
+	}
+
+	return deliveries, int(total), nil
+}
+
+// GetPendingWebhookDeliveries returns the failed attempts whose retry is
+// still due, oldest first.
+func GetPendingWebhookDeliveries() ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	if err := DB.Where("next_attempt_at IS NOT NULL").Order("id ASC").Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to query pending webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ClearWebhookRetry marks the retry of a failed attempt as made or
+// abandoned.
+func ClearWebhookRetry(id uint) error {
+	if err := DB.Model(&WebhookDelivery{}).Where("id = ?", id).Update("next_attempt_at", nil).Error; err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
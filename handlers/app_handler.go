@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"tenant-manager/models"
+	"tenant-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AppHandler struct {
+	service *services.TenantService
+}
+
+func NewAppHandler(service *services.TenantService) *AppHandler {
+	return &AppHandler{
+		service: service,
+	}
+}
+
+func (h *AppHandler) ListApps(c *gin.Context) {
+	apps, err := h.service.ListApps()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve apps", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Apps retrieved successfully", apps))
+}
+
+func (h *AppHandler) GetApp(c *gin.Context) {
+	app, err := h.service.GetApp(c.Param("name"))
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("App not found", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve app", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("App retrieved successfully", app))
+}
+
+func (h *AppHandler) CreateApp(c *gin.Context) {
+	var req models.CreateAppRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	app, err := h.service.CreateApp(req)
+	if err != nil {
+		if contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("App already exists", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create app", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("App created successfully", app))
+}
+
+func (h *AppHandler) DeleteApp(c *gin.Context) {
+	if err := h.service.DeleteApp(c.Param("name")); err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("App not found", err))
+			return
+		}
+		if contains(err.Error(), "in use") || contains(err.Error(), "built in") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("App cannot be deleted", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to delete app", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("App deleted successfully", nil))
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"tenant-manager/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditParamsKey  = "audit_params"
+	maxAuditBody    = 64 * 1024
+	maxAuditCapture = 4 * 1024
+)
+
+var redactedAuditFields = []string{"password", "secret", "token", "key"}
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.body.Len() < maxAuditCapture {
+		w.body.Write(data[:min(len(data), maxAuditCapture-w.body.Len())])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Audit records the wrapped request in the append-only audit log once the
+// handler chain has finished, whatever its outcome.
+func Audit(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startedAt := time.Now()
+
+		params := map[string]interface{}{}
+		for _, param := range c.Params {
+			params[param.Key] = param.Value
+		}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			params["query"] = redactParams(query)
+		}
+
+		body := readAuditBody(c)
+		if len(body) > 0 {
+			var decoded interface{}
+			if err := json.Unmarshal(body, &decoded); err == nil {
+				params["body"] = redactValue(decoded)
+			}
+		}
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if extra, ok := c.Get(auditParamsKey); ok {
+			for key, value := range extra.(map[string]interface{}) {
+				params[key] = value
+			}
+		}
+
+		// Only tenant actions name a tenant; apps use the same parameter
+		// and body field for their own names.
+		tenantName := ""
+		if strings.HasPrefix(action, "tenant.") {
+			tenantName = c.Param("name")
+			if tenantName == "" {
+				if bodyMap, ok := params["body"].(map[string]interface{}); ok {
+					tenantName, _ = bodyMap["name"].(string)
+				}
+			}
+		}
+
+		status := writer.Status()
+		outcome := database.AuditOutcomeSuccess
+		errMsg := ""
+		if status >= 400 {
+			outcome = database.AuditOutcomeFailure
+			errMsg = extractErrorMessage(writer.body.Bytes())
+		}
+
+		paramsJSON, _ := json.Marshal(params)
+
+		entry := &database.AuditLog{
+			Actor:      c.GetString(ContextActorKey),
+			Role:       c.GetString(ContextRoleKey),
+			Action:     action,
+			TenantName: tenantName,
+			Params:     string(paramsJSON),
+			Outcome:    outcome,
+			StatusCode: status,
+			Error:      errMsg,
+			ClientIP:   c.ClientIP(),
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		}
+
+		if err := database.CreateAuditLog(entry); err != nil {
+			log.Printf("Warning: failed to write audit log for %s: %v", action, err)
+		}
+	}
+}
+
+// AddAuditParam attaches handler-specific details to the current audit entry.
+func AddAuditParam(c *gin.Context, key string, value interface{}) {
+	extra, ok := c.Get(auditParamsKey)
+	if !ok {
+		extra = map[string]interface{}{}
+		c.Set(auditParamsKey, extra)
+	}
+	extra.(map[string]interface{})[key] = value
+}
+
+func readAuditBody(c *gin.Context) []byte {
+	if c.Request.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBody+1))
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+	if len(body) > maxAuditBody {
+		return nil
+	}
+	return body
+}
+
+func extractErrorMessage(body []byte) string {
+	var response struct {
+		Message string  `json:"message"`
+		Error   *string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ""
+	}
+	if response.Error != nil && *response.Error != "" {
+		return *response.Error
+	}
+	return response.Message
+}
+
+func redactParams(values map[string][]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if isRedactedField(key) {
+			result[key] = "[REDACTED]"
+			continue
+		}
+		if len(value) == 1 {
+			result[key] = value[0]
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isRedactedField(key) {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			// Tenant env values are set by users and often hold
+			// credentials under arbitrary names.
+			if env, ok := nested.(map[string]interface{}); ok && key == "env" {
+				for name, value := range env {
+					if value != nil {
+						env[name] = "[REDACTED]"
+					}
+				}
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+		return v
+	}
+	return value
+}
+
+func isRedactedField(key string) bool {
+	lower := strings.ToLower(key)
+	for _, field := range redactedAuditFields {
+		if strings.Contains(lower, field) {
+			return true
+		}
+	}
+	return false
+}
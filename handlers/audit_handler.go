@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct{}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid audit filter", err))
+		return
+	}
+
+	if c.Query("format") == "jsonl" {
+		h.exportAuditLogs(c, filter)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(models.DefaultAuditPerPage)))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = models.DefaultAuditPerPage
+	}
+	if perPage > models.MaxAuditPerPage {
+		perPage = models.MaxAuditPerPage
+	}
+
+	entries, total, err := database.GetAuditLogs(filter, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve audit logs", err))
+		return
+	}
+
+	result := make([]models.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, toAuditEntry(entry))
+	}
+
+	meta := models.PaginationMeta{
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+		MaxPage: int(math.Ceil(float64(total) / float64(perPage))),
+	}
+
+	c.JSON(http.StatusOK, models.NewPaginatedResponse("Audit logs retrieved successfully", result, meta))
+}
+
+func (h *AuditHandler) exportAuditLogs(c *gin.Context, filter database.AuditFilter) {
+	filename := fmt.Sprintf("audit-%s.jsonl", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	err := database.EachAuditLog(filter, func(entry database.AuditLog) error {
+		return encoder.Encode(toAuditEntry(entry))
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+func parseAuditFilter(c *gin.Context) (database.AuditFilter, error) {
+	filter := database.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		TenantName: c.Query("tenant"),
+		Outcome:    c.Query("outcome"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("since must be an RFC3339 timestamp")
+		}
+		filter.Since = &parsed
+	}
+
+	if until := c.Query("until"); until != "" {
+		parsed, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("until must be an RFC3339 timestamp")
+		}
+		filter.Until = &parsed
+	}
+
+	return filter, nil
+}
+
+func toAuditEntry(entry database.AuditLog) models.AuditEntry {
+	var params json.RawMessage
+	if entry.Params != "" {
+		params = json.RawMessage(entry.Params)
+	}
+
+	return models.AuditEntry{
+		ID:         int(entry.ID),
+		Actor:      entry.Actor,
+		Role:       entry.Role,
+		Action:     entry.Action,
+		Tenant:     entry.TenantName,
+		Params:     params,
+		Outcome:    entry.Outcome,
+		StatusCode: entry.StatusCode,
+		Error:      entry.Error,
+		ClientIP:   entry.ClientIP,
+		StartedAt:  entry.StartedAt,
+		FinishedAt: entry.FinishedAt,
+		DurationMs: entry.FinishedAt.Sub(entry.StartedAt).Milliseconds(),
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"tenant-manager/config"
+	"tenant-manager/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContextActorKey = "actor"
+	ContextRoleKey  = "role"
+
+	AnonymousActor = "anonymous"
+)
+
+// AuthMiddleware resolves the caller from an API key or, failing that, a
+// verified TLS client certificate. When neither is configured the API
+// stays open and requests run as an anonymous actor without a role, so
+// role-restricted endpoints remain unavailable. Keys are read from the
+// settings store on every request so a reload can rotate them.
+func AuthMiddleware(settings *config.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Get()
+		apiKeys := cfg.APIKeys
+
+		provided := extractAPIKey(c)
+		if provided != "" {
+			for _, key := range apiKeys {
+				if subtle.ConstantTimeCompare([]byte(provided), []byte(key.Key)) == 1 {
+					c.Set(ContextActorKey, key.Name)
+					c.Set(ContextRoleKey, key.Role)
+					c.Next()
+					return
+				}
+			}
+
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse(
+				"Authentication failed",
+				fmt.Errorf("invalid API key"),
+			))
+			return
+		}
+
+		if cert := verifiedClientCert(c); cert != nil {
+			c.Set(ContextActorKey, "cert:"+cert.Subject.CommonName)
+			c.Set(ContextRoleKey, cfg.TLSClientRole)
+			c.Next()
+			return
+		}
+
+		if len(apiKeys) == 0 {
+			c.Set(ContextActorKey, AnonymousActor)
+			c.Set(ContextRoleKey, "")
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse(
+			"Authentication required",
+			fmt.Errorf("missing API key"),
+		))
+	}
+}
+
+func verifiedClientCert(c *gin.Context) *x509.Certificate {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextRoleKey) != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.NewErrorResponse(
+				"Insufficient permissions",
+				fmt.Errorf("%s role required", role),
+			))
+			return
+		}
+		c.Next()
+	}
+}
+
+// extractAPIKey accepts a bearer token, the X-API-Key header or, for
+// browser WebSocket clients that cannot set headers, the api_key query.
+func extractAPIKey(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("api_key")
+}
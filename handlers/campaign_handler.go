@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"tenant-manager/models"
+	"tenant-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CampaignHandler struct {
+	runner *services.CampaignRunner
+}
+
+func NewCampaignHandler(runner *services.CampaignRunner) *CampaignHandler {
+	return &CampaignHandler{
+		runner: runner,
+	}
+}
+
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var req models.CreateCampaignRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	AddAuditParam(c, "image", req.Image)
+
+	ctx := context.Background()
+	campaign, err := h.runner.CreateCampaign(ctx, req, c.GetString(ContextActorKey))
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("Tenant not found", err))
+			return
+		}
+		if contains(err.Error(), "no tenants match") {
+			c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse("No tenants selected", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create campaign", err))
+		return
+	}
+
+	AddAuditParam(c, "campaign_id", campaign.ID)
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("Campaign started successfully", campaign))
+}
+
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	campaigns, err := h.runner.ListCampaigns()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve campaigns", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Campaigns retrieved successfully", campaigns))
+}
+
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.runner.GetCampaign(id)
+	if err != nil {
+		respondCampaignError(c, err, "Failed to retrieve campaign")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Campaign retrieved successfully", campaign))
+}
+
+func (h *CampaignHandler) PauseCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.runner.PauseCampaign(id)
+	if err != nil {
+		respondCampaignError(c, err, "Failed to pause campaign")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Campaign paused successfully", campaign))
+}
+
+func (h *CampaignHandler) ResumeCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.runner.ResumeCampaign(id)
+	if err != nil {
+		respondCampaignError(c, err, "Failed to resume campaign")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Campaign resumed successfully", campaign))
+}
+
+func (h *CampaignHandler) AbortCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.runner.AbortCampaign(id)
+	if err != nil {
+		respondCampaignError(c, err, "Failed to abort campaign")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Campaign aborted successfully", campaign))
+}
+
+func campaignID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid campaign id", err))
+		return 0, false
+	}
+	AddAuditParam(c, "campaign_id", id)
+	return uint(id), true
+}
+
+func respondCampaignError(c *gin.Context, err error, message string) {
+	if contains(err.Error(), "not found") {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Campaign not found", err))
+		return
+	}
+	if contains(err.Error(), "cannot be") {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(message, err))
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, models.NewErrorResponse(message, err))
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"tenant-manager/config"
+	"tenant-manager/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+	settings *config.Store
+}
+
+func NewConfigHandler(settings *config.Store) *ConfigHandler {
+	return &ConfigHandler{settings: settings}
+}
+
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Configuration retrieved successfully", h.settings.Get().Redacted()))
+}
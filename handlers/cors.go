@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"strings"
+	"tenant-manager/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware answers preflight requests and sets the CORS headers from
+// the current settings.
+func CORSMiddleware(settings *config.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cors := settings.Get().CORS
+
+		origin := allowedOrigin(cors.AllowedOrigins, c.GetHeader("Origin"))
+		if origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+		}
+		if cors.AllowCredentials {
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		c.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		c.Writer.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func allowedOrigin(allowed []string, origin string) string {
+	for _, candidate := range allowed {
+		if candidate == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(candidate, origin) {
+			return origin
+		}
+	}
+	return ""
+}
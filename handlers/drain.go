@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"tenant-manager/models"
+	"tenant-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RejectWhileDraining turns away mutating requests once shutdown has
+// started. Reads keep working until the server stops listening.
+func RejectWhileDraining(service *services.TenantService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if service.Draining() {
+			c.Header("Retry-After", "30")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+				"Server is shutting down",
+				services.ErrDraining,
+			))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"tenant-manager/models"
+	"tenant-manager/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+type ExecHandler struct {
+	service *services.TenantService
+}
+
+func NewExecHandler(service *services.TenantService) *ExecHandler {
+	return &ExecHandler{
+		service: service,
+	}
+}
+
+// execControl is sent by the client as a text frame. Binary frames are
+// written to the TTY as-is.
+type execControl struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Rows uint   `json:"rows,omitempty"`
+	Cols uint   `json:"cols,omitempty"`
+}
+
+type execExit struct {
+	Type     string `json:"type"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type execFrame struct {
+	payloadType byte
+	data        []byte
+}
+
+var execFrameCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		frame := v.(execFrame)
+		return frame.data, frame.payloadType, nil
+	},
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		frame := v.(*execFrame)
+		frame.payloadType = payloadType
+		frame.data = data
+		return nil
+	},
+}
+
+func (h *ExecHandler) Exec(c *gin.Context) {
+	name := c.Param("name")
+	cmd := c.QueryArray("cmd")
+	rows, _ := strconv.ParseUint(c.DefaultQuery("rows", "0"), 10, 32)
+	cols, _ := strconv.ParseUint(c.DefaultQuery("cols", "0"), 10, 32)
+
+	// The exec is only created for a request that can be upgraded, so a
+	// plain HTTP request does not leave a shell behind.
+	if !isWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"WebSocket upgrade required",
+			errors.New("expected a websocket upgrade request"),
+		))
+		return
+	}
+
+	ctx := context.Background()
+	session, err := h.service.ExecTenant(ctx, name, cmd, uint(rows), uint(cols))
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("Tenant not found", err))
+			return
+		}
+		if contains(err.Error(), "not running") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Container is not running", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to start exec session", err))
+		return
+	}
+	defer session.Close()
+
+	startedAt := time.Now()
+	AddAuditParam(c, "exec_id", session.ID)
+
+	exitCode := -1
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			exitCode = h.pipe(ctx, ws, session)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+
+	AddAuditParam(c, "exit_code", exitCode)
+	AddAuditParam(c, "session_seconds", int(time.Since(startedAt).Seconds()))
+}
+
+// isWebSocketUpgrade checks the headers the WebSocket handshake requires.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if r.Method != http.MethodGet || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	if r.Header.Get("Sec-WebSocket-Key") == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return false
+	}
+	for _, token := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ExecHandler) pipe(ctx context.Context, ws *websocket.Conn, session *services.ExecSession) int {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := session.Read(buf)
+			if n > 0 {
+				frame := execFrame{payloadType: websocket.BinaryFrame, data: buf[:n]}
+				if sendErr := execFrameCodec.Send(ws, frame); sendErr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			var frame execFrame
+			if err := execFrameCodec.Receive(ws, &frame); err != nil {
+				session.Close()
+				return
+			}
+
+			if frame.payloadType == websocket.BinaryFrame {
+				if _, err := session.Write(frame.data); err != nil {
+					return
+				}
+				continue
+			}
+
+			var control execControl
+			if err := json.Unmarshal(frame.data, &control); err != nil {
+				continue
+			}
+
+			switch control.Type {
+			case "input":
+				if _, err := session.Write([]byte(control.Data)); err != nil {
+					return
+				}
+			case "resize":
+				if control.Rows > 0 && control.Cols > 0 {
+					session.Resize(ctx, control.Rows, control.Cols)
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	exitCode, err := session.ExitCode(ctx)
+	if err != nil {
+		exitCode = -1
+	}
+
+	exitMessage, _ := json.Marshal(execExit{Type: "exit", ExitCode: exitCode})
+	execFrameCodec.Send(ws, execFrame{payloadType: websocket.TextFrame, data: exitMessage})
+
+	return exitCode
+}
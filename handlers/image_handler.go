@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"tenant-manager/config"
+	"tenant-manager/models"
+	"tenant-manager/services"
+	"tenant-manager/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageHandler struct {
+	service  *services.TenantService
+	settings *config.Store
+}
+
+func NewImageHandler(service *services.TenantService, settings *config.Store) *ImageHandler {
+	return &ImageHandler{
+		service:  service,
+		settings: settings,
+	}
+}
+
+// PullImage pulls an image. With ?format=jsonl the pull progress is
+// streamed as one JSON object per line.
+func (h *ImageHandler) PullImage(c *gin.Context) {
+	var req models.PullImageRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+			return
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	AddAuditParam(c, "image", req.Image)
+
+	ctx := context.Background()
+	if c.Query("format") == "jsonl" {
+		h.streamPull(c, ctx, req)
+		return
+	}
+
+	pulled, err := h.service.PullImage(ctx, req, nil)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.NewErrorResponse("Failed to pull image", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Image pulled successfully", pulled))
+}
+
+func (h *ImageHandler) streamPull(c *gin.Context, ctx context.Context, req models.PullImageRequest) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	send := func(event models.ImagePullEvent) {
+		encoder.Encode(event)
+		c.Writer.Flush()
+	}
+
+	pulled, err := h.service.PullImage(ctx, req, func(p utils.PullProgress) {
+		send(models.ImagePullEvent{Status: p.Status, ID: p.ID, Current: p.Current, Total: p.Total})
+	})
+	if err != nil {
+		send(models.ImagePullEvent{Status: "failed", Error: err.Error()})
+		c.Error(err)
+		return
+	}
+
+	send(models.ImagePullEvent{Status: "done", ImageDigest: pulled.ImageDigest})
+}
+
+// LoadImage imports a tarball produced by "docker save". The tarball is
+// either the raw request body or the "image" field of a multipart form.
+func (h *ImageHandler) LoadImage(c *gin.Context) {
+	limit := int64(h.settings.Get().ImageLoadMaxMB) << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	tarball, err := imageUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid upload", err))
+		return
+	}
+
+	ctx := context.Background()
+	loaded, err := h.service.LoadImage(ctx, tarball)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse("Image tarball too large", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to load image", err))
+		return
+	}
+
+	AddAuditParam(c, "images", loaded.Images)
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Image loaded successfully", loaded))
+}
+
+func imageUpload(c *gin.Context) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return c.Request.Body, nil
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the form has no image field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			return part, nil
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"tenant-manager/models"
+	"tenant-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrphanHandler struct {
+	collector *services.OrphanCollector
+}
+
+func NewOrphanHandler(collector *services.OrphanCollector) *OrphanHandler {
+	return &OrphanHandler{
+		collector: collector,
+	}
+}
+
+func (h *OrphanHandler) ListOrphans(c *gin.Context) {
+	ctx := context.Background()
+	orphans, err := h.collector.Scan(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to scan for orphaned resources", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Orphaned resources retrieved successfully", orphans))
+}
+
+func (h *OrphanHandler) CollectOrphans(c *gin.Context) {
+	var req models.CollectOrphansRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	ctx := context.Background()
+	result, err := h.collector.Collect(ctx, req.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to remove orphaned resources", err))
+		return
+	}
+
+	AddAuditParam(c, "removed", len(result.Removed))
+	AddAuditParam(c, "failed", len(result.Failed))
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Orphaned resources removed", result))
+}
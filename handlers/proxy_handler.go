@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"tenant-manager/models"
+	"tenant-manager/services"
+	"time"
+)
+
+// TenantProxy routes "<tenant>.<base-domain>" and "/t/<tenant>/" requests
+// to the tenant container over the Docker network and hands everything
+// else to the API router.
+type TenantProxy struct {
+	service *services.TenantService
+	next    http.Handler
+}
+
+func NewTenantProxy(service *services.TenantService, next http.Handler) *TenantProxy {
+	return &TenantProxy{
+		service: service,
+		next:    next,
+	}
+}
+
+func (p *TenantProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, byHost := p.service.TenantFromHost(r.Host)
+	if !byHost {
+		var byPath bool
+		name, byPath = p.service.TenantFromPath(r.URL.Path)
+		if !byPath {
+			p.next.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	target, err := p.service.ResolveProxyTarget(ctx, name)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		writeProxyError(w, status, "Tenant is not available", err)
+		return
+	}
+
+	prefix := services.ProxyPathPrefix + name
+	usesPrefix := p.service.UsesPathPrefix(name)
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Host = pr.In.Host
+
+			// With a path-prefixed base URL, host-routed requests still
+			// need the prefix the app expects; apps without one get the
+			// prefix of path-routed requests stripped.
+			switch {
+			case byHost && usesPrefix && !strings.HasPrefix(pr.Out.URL.Path, prefix):
+				pr.Out.URL.Path = prefix + pr.Out.URL.Path
+				pr.Out.URL.RawPath = ""
+			case !byHost && !usesPrefix:
+				pr.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(pr.Out.URL.Path, prefix), "/")
+				pr.Out.URL.RawPath = ""
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeProxyError(w, http.StatusBadGateway, "Failed to reach tenant", err)
+		},
+	}
+
+	proxy.ServeHTTP(w, r)
+}
+
+func writeProxyError(w http.ResponseWriter, status int, message string, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.NewErrorResponse(message, err))
+}
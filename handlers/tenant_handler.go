@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"tenant-manager/models"
 	"tenant-manager/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,8 +37,12 @@ func (h *TenantHandler) CreateTenant(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	tenant, err := h.service.CreateTenant(ctx, req.Name)
+	tenant, err := h.service.CreateTenant(ctx, req)
 	if err != nil {
+		if contains(err.Error(), "is reserved") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
 		if contains(err.Error(), "UNIQUE constraint failed") {
 			c.JSON(http.StatusConflict, models.NewErrorResponse(
 				"Tenant already exists",
@@ -43,6 +50,18 @@ func (h *TenantHandler) CreateTenant(c *gin.Context) {
 			))
 			return
 		}
+		if contains(err.Error(), "already assigned") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Domain already in use", err))
+			return
+		}
+		if contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant has an unfinished operation", err))
+			return
+		}
+		if contains(err.Error(), "app") && contains(err.Error(), "not found") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Unknown app", err))
+			return
+		}
 
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create tenant", err))
 		return
@@ -52,12 +71,20 @@ func (h *TenantHandler) CreateTenant(c *gin.Context) {
 }
 
 func (h *TenantHandler) ListTenants(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
+	query, err := parseListTenantsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid tenant query", err))
+		return
+	}
 
 	ctx := context.Background()
-	tenants, meta, err := h.service.ListTenants(ctx, page, perPage)
+	tenants, meta, err := h.service.ListTenants(ctx, query)
 	if err != nil {
+		if contains(err.Error(), "invalid query") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid tenant query", err))
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve tenants", err))
 		return
 	}
@@ -65,6 +92,58 @@ func (h *TenantHandler) ListTenants(c *gin.Context) {
 	c.JSON(http.StatusOK, models.NewPaginatedResponse("Tenants retrieved successfully", tenants, meta))
 }
 
+func parseListTenantsQuery(c *gin.Context) (models.ListTenantsQuery, error) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
+
+	query := models.ListTenantsQuery{
+		Page:    page,
+		PerPage: perPage,
+		Query:   strings.TrimSpace(c.Query("q")),
+		Sort:    c.Query("sort"),
+		Order:   strings.ToLower(c.Query("order")),
+	}
+
+	labels, err := models.ParseLabelSelector(c.Query("labels"))
+	if err != nil {
+		return query, err
+	}
+	query.Labels = labels
+
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			query.Statuses = append(query.Statuses, strings.TrimSpace(s))
+		}
+	}
+
+	if after := c.Query("created_after"); after != "" {
+		parsed, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			return query, fmt.Errorf("created_after must be an RFC3339 timestamp")
+		}
+		query.CreatedAfter = &parsed
+	}
+
+	if before := c.Query("created_before"); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return query, fmt.Errorf("created_before must be an RFC3339 timestamp")
+		}
+		query.CreatedBefore = &parsed
+	}
+
+	// An empty cursor starts a keyset listing from the beginning.
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		parsed, err := models.ParseTenantCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = parsed
+	}
+
+	return query, query.Normalize()
+}
+
 func (h *TenantHandler) GetTenant(c *gin.Context) {
 	name := c.Param("name")
 
@@ -86,6 +165,155 @@ func (h *TenantHandler) GetTenant(c *gin.Context) {
 	c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant retrieved successfully", tenant))
 }
 
+func (h *TenantHandler) UpdateTenant(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	if req.ImageTag != nil {
+		AddAuditParam(c, "image_tag", *req.ImageTag)
+	}
+
+	ctx := context.Background()
+	result, err := h.service.UpdateTenant(ctx, name, req)
+	if err != nil {
+		if contains(err.Error(), "tenant not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+		if contains(err.Error(), "invalid labels") || contains(err.Error(), "invalid env") || contains(err.Error(), "invalid image") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
+		if contains(err.Error(), "must be running") || contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant cannot be recreated now", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update tenant", err))
+		return
+	}
+
+	if len(result.Pending) > 0 {
+		c.JSON(http.StatusAccepted, models.NewSuccessResponse("Tenant updated, some changes are pending", result))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant updated successfully", result))
+}
+
+func (h *TenantHandler) RenameTenant(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.RenameTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	AddAuditParam(c, "new_name", req.Name)
+
+	ctx := context.Background()
+	tenant, err := h.service.RenameTenant(ctx, name, req)
+	if err != nil {
+		if contains(err.Error(), "tenant not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+		if contains(err.Error(), "is reserved") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
+		if contains(err.Error(), "already named") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
+		if contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant name is taken", err))
+			return
+		}
+		if contains(err.Error(), "must be running") || contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant cannot be renamed now", err))
+			return
+		}
+		if contains(err.Error(), "rolled back") {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Rename failed and was rolled back", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to rename tenant", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant renamed successfully", tenant))
+}
+
+func (h *TenantHandler) CloneTenant(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.CloneTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	AddAuditParam(c, "new_name", req.Name)
+	AddAuditParam(c, "reset_credentials", req.ResetCredentials)
+
+	ctx := context.Background()
+	tenant, err := h.service.CloneTenant(ctx, name, req)
+	if err != nil {
+		if contains(err.Error(), "is reserved") {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
+		if contains(err.Error(), "tenant not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+		if contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant name is taken", err))
+			return
+		}
+		if contains(err.Error(), "must be running") || contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant cannot be cloned now", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to clone tenant", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("Tenant cloned successfully", tenant))
+}
+
 func (h *TenantHandler) DeleteTenant(c *gin.Context) {
 	name := c.Param("name")
 
@@ -98,6 +326,10 @@ func (h *TenantHandler) DeleteTenant(c *gin.Context) {
 			))
 			return
 		}
+		if contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant has an unfinished operation", err))
+			return
+		}
 
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to delete tenant", err))
 		return
@@ -119,6 +351,11 @@ func (h *TenantHandler) StopContainer(c *gin.Context) {
 			return
 		}
 
+		if contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant has an unfinished operation", err))
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to stop container", err))
 		return
 	}
@@ -139,6 +376,11 @@ func (h *TenantHandler) StartContainer(c *gin.Context) {
 			return
 		}
 
+		if contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant has an unfinished operation", err))
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to start container", err))
 		return
 	}
@@ -149,6 +391,176 @@ func (h *TenantHandler) StartContainer(c *gin.Context) {
 	}))
 }
 
+func (h *TenantHandler) UpdateIdlePolicy(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.IdlePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := models.ValidateIdleTimeout(*req.IdleTimeoutMinutes); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	ctx := context.Background()
+	if err := h.service.UpdateIdlePolicy(ctx, name, *req.IdleTimeoutMinutes); err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update idle policy", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Idle policy updated successfully", gin.H{
+		"name":                 name,
+		"idle_timeout_minutes": *req.IdleTimeoutMinutes,
+	}))
+}
+
+func (h *TenantHandler) SetCustomDomain(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.CustomDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(req.Domain))
+	if domain != "" {
+		if err := models.ValidateDomain(domain); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+			return
+		}
+	}
+
+	ctx := context.Background()
+	url, err := h.service.SetCustomDomain(ctx, name, domain)
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+		if contains(err.Error(), "already assigned") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Domain already in use", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update custom domain", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Custom domain updated successfully", gin.H{
+		"name":          name,
+		"custom_domain": domain,
+		"url":           url,
+	}))
+}
+
+func (h *TenantHandler) UpgradeTenant(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.UpgradeTenantRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+			return
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	AddAuditParam(c, "image", req.Image)
+
+	ctx := context.Background()
+	result, err := h.service.UpgradeTenant(ctx, name, req)
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+		if contains(err.Error(), "must be running") || contains(err.Error(), "pending") {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Tenant cannot be upgraded now", err))
+			return
+		}
+		if result != nil && result.RolledBack {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Upgrade failed and was rolled back", err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to upgrade tenant", err))
+		return
+	}
+
+	if !result.Changed {
+		c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant already runs this image", result))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant upgraded successfully", result))
+}
+
+func (h *TenantHandler) GetTenantHealth(c *gin.Context) {
+	name := c.Param("name")
+
+	ctx := context.Background()
+	health, err := h.service.GetTenantHealth(ctx, name)
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve tenant health", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Tenant health retrieved successfully", health))
+}
+
+func (h *TenantHandler) ListTenantEvents(c *gin.Context) {
+	name := c.Param("name")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+
+	ctx := context.Background()
+	events, meta, err := h.service.GetTenantEvents(ctx, name, page, perPage)
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Tenant not found",
+				err,
+			))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve tenant events", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewPaginatedResponse("Tenant events retrieved successfully", events, meta))
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
 		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
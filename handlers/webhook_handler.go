@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"tenant-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service: service,
+	}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid request body", err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Validation failed", err))
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = services.GenerateWebhookSecret()
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	webhook := &database.Webhook{
+		URL:    req.URL,
+		Events: strings.Join(req.Events, ","),
+		Secret: secret,
+		Active: active,
+	}
+
+	if err := database.CreateWebhook(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create webhook", err))
+		return
+	}
+
+	response := toWebhook(webhook)
+	response.Secret = secret
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("Webhook created successfully", response))
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := database.GetAllWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve webhooks", err))
+		return
+	}
+
+	result := make([]models.Webhook, 0, len(webhooks))
+	for i := range webhooks {
+		result = append(result, toWebhook(&webhooks[i]))
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Webhooks retrieved successfully", result))
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, ok := h.lookupWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Webhook retrieved successfully", toWebhook(webhook)))
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := h.lookupWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteWebhook(webhook.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to delete webhook", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Webhook deleted successfully", nil))
+}
+
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	webhook, ok := h.lookupWebhook(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	deliveries, total, err := database.GetWebhookDeliveries(webhook.ID, page, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve deliveries", err))
+		return
+	}
+
+	result := make([]models.WebhookDelivery, 0, len(deliveries))
+	for i := range deliveries {
+		result = append(result, toWebhookDelivery(&deliveries[i]))
+	}
+
+	meta := models.PaginationMeta{
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+		MaxPage: int(math.Ceil(float64(total) / float64(perPage))),
+	}
+
+	c.JSON(http.StatusOK, models.NewPaginatedResponse("Deliveries retrieved successfully", result, meta))
+}
+
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	webhook, ok := h.lookupWebhook(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	delivery, err := h.service.TestFire(ctx, *webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to send test delivery", err))
+		return
+	}
+
+	if !delivery.Success {
+		c.JSON(http.StatusBadGateway, models.NewErrorResponse(
+			"Test delivery failed",
+			fmt.Errorf("%s", delivery.Error),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("Test delivery sent successfully", toWebhookDelivery(delivery)))
+}
+
+func (h *WebhookHandler) lookupWebhook(c *gin.Context) (*database.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid webhook id", err))
+		return nil, false
+	}
+
+	webhook, err := database.GetWebhookByID(uint(id))
+	if err != nil {
+		if contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("Webhook not found", err))
+			return nil, false
+		}
+
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to retrieve webhook", err))
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func toWebhook(webhook *database.Webhook) models.Webhook {
+	return models.Webhook{
+		ID:        int(webhook.ID),
+		URL:       webhook.URL,
+		Events:    webhook.EventList(),
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+		UpdatedAt: webhook.UpdatedAt,
+	}
+}
+
+func toWebhookDelivery(delivery *database.WebhookDelivery) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		ID:         int(delivery.ID),
+		WebhookID:  int(delivery.WebhookID),
+		EventID:    delivery.EventID,
+		Event:      delivery.Event,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Success:    delivery.Success,
+		Error:      delivery.Error,
+		DurationMs: delivery.DurationMs,
+		CreatedAt:  delivery.CreatedAt,
+
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+}
@@ -1,21 +1,37 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"tenant-manager/config"
 	"tenant-manager/database"
 	"tenant-manager/handlers"
+	"tenant-manager/models"
 	"tenant-manager/services"
 	"tenant-manager/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	cfg := config.LoadConfig()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	settings := config.NewStore(cfg)
+	go reloadOnSIGHUP(settings)
 
 	dbDir := filepath.Dir(cfg.DBPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
@@ -25,17 +41,52 @@ func main() {
 	if err := database.InitDB(cfg.DBPath); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer database.CloseDB()
 
-	dockerClient, err := utils.NewDockerClient()
+	dockerClient, err := utils.NewDockerClient(settings)
 	if err != nil {
 		log.Fatalf("Failed to create Docker client: %v", err)
 	}
-	defer dockerClient.Close()
 
-	tenantService := services.NewTenantService(dockerClient, cfg.BaseDir)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	webhookService := services.NewWebhookService()
+	tenantService := services.NewTenantService(settings, dockerClient, webhookService)
+
+	tenantService.RecoverOperations(ctx)
+	webhookService.ResumePending()
+	tenantService.ReconnectTenantNetworks(ctx)
+
+	var background sync.WaitGroup
+	runInBackground := func(run func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
+
+	runInBackground(services.NewHealthProber(tenantService, settings).Run)
+	runInBackground(services.NewRecoveryMonitor(tenantService, settings).Run)
+
+	tenantService.RestoreWakeListeners()
+	runInBackground(services.NewIdleMonitor(tenantService, settings).Run)
+
+	orphanCollector := services.NewOrphanCollector(tenantService, settings)
+	runInBackground(orphanCollector.Run)
+
+	campaignRunner := services.NewCampaignRunner(tenantService)
+	runInBackground(campaignRunner.Run)
 
 	tenantHandler := handlers.NewTenantHandler(tenantService)
+	execHandler := handlers.NewExecHandler(tenantService)
+	auditHandler := handlers.NewAuditHandler()
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	configHandler := handlers.NewConfigHandler(settings)
+	orphanHandler := handlers.NewOrphanHandler(orphanCollector)
+	campaignHandler := handlers.NewCampaignHandler(campaignRunner)
+	imageHandler := handlers.NewImageHandler(tenantService, settings)
+	appHandler := handlers.NewAppHandler(tenantService)
 
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -43,19 +94,7 @@ func main() {
 
 	router := gin.Default()
 
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+	router.Use(handlers.CORSMiddleware(settings))
 
 	// API routes first
 	router.GET("/health", func(c *gin.Context) {
@@ -69,16 +108,68 @@ func main() {
 	})
 
 	api := router.Group("/api")
+	api.Use(handlers.AuthMiddleware(settings), handlers.RejectWhileDraining(tenantService))
 	{
 		tenants := api.Group("/tenants")
 		{
-			tenants.POST("", tenantHandler.CreateTenant)
+			tenants.POST("", handlers.Audit("tenant.create"), tenantHandler.CreateTenant)
 			tenants.GET("", tenantHandler.ListTenants)
 			tenants.GET("/:name", tenantHandler.GetTenant)
-			tenants.DELETE("/:name", tenantHandler.DeleteTenant)
+			tenants.PATCH("/:name", handlers.Audit("tenant.update"), tenantHandler.UpdateTenant)
+			tenants.DELETE("/:name", handlers.Audit("tenant.delete"), tenantHandler.DeleteTenant)
 
-			tenants.PUT("/:name/stop", tenantHandler.StopContainer)
-			tenants.PUT("/:name/start", tenantHandler.StartContainer)
+			tenants.PUT("/:name/stop", handlers.Audit("tenant.stop"), tenantHandler.StopContainer)
+			tenants.PUT("/:name/start", handlers.Audit("tenant.start"), tenantHandler.StartContainer)
+
+			tenants.PUT("/:name/idle-policy", handlers.Audit("tenant.idle_policy"), tenantHandler.UpdateIdlePolicy)
+			tenants.PUT("/:name/domain", handlers.Audit("tenant.domain"), tenantHandler.SetCustomDomain)
+			tenants.POST("/:name/upgrade", handlers.Audit("tenant.upgrade"), tenantHandler.UpgradeTenant)
+			tenants.POST("/:name/rename", handlers.Audit("tenant.rename"), tenantHandler.RenameTenant)
+			tenants.POST("/:name/clone", handlers.Audit("tenant.clone"), tenantHandler.CloneTenant)
+			tenants.GET("/:name/health", tenantHandler.GetTenantHealth)
+			tenants.GET("/:name/events", tenantHandler.ListTenantEvents)
+			tenants.GET("/:name/exec", handlers.Audit("tenant.exec"), handlers.RequireRole(models.RoleAdmin), execHandler.Exec)
+		}
+
+		apps := api.Group("/apps")
+		{
+			apps.GET("", appHandler.ListApps)
+			apps.GET("/:name", appHandler.GetApp)
+			apps.POST("", handlers.Audit("app.create"), handlers.RequireRole(models.RoleAdmin), appHandler.CreateApp)
+			apps.DELETE("/:name", handlers.Audit("app.delete"), handlers.RequireRole(models.RoleAdmin), appHandler.DeleteApp)
+		}
+
+		api.GET("/audit", handlers.RequireRole(models.RoleAdmin), auditHandler.ListAuditLogs)
+		admin := api.Group("/admin")
+		admin.Use(handlers.RequireRole(models.RoleAdmin))
+		{
+			admin.GET("/config", configHandler.GetConfig)
+			admin.GET("/orphans", orphanHandler.ListOrphans)
+			admin.DELETE("/orphans", handlers.Audit("orphans.collect"), orphanHandler.CollectOrphans)
+			admin.POST("/images/pull", handlers.Audit("image.pull"), imageHandler.PullImage)
+			admin.POST("/images/load", handlers.Audit("image.load"), imageHandler.LoadImage)
+		}
+
+		campaigns := api.Group("/campaigns")
+		campaigns.Use(handlers.RequireRole(models.RoleAdmin))
+		{
+			campaigns.POST("", handlers.Audit("campaign.create"), campaignHandler.CreateCampaign)
+			campaigns.GET("", campaignHandler.ListCampaigns)
+			campaigns.GET("/:id", campaignHandler.GetCampaign)
+			campaigns.POST("/:id/pause", handlers.Audit("campaign.pause"), campaignHandler.PauseCampaign)
+			campaigns.POST("/:id/resume", handlers.Audit("campaign.resume"), campaignHandler.ResumeCampaign)
+			campaigns.POST("/:id/abort", handlers.Audit("campaign.abort"), campaignHandler.AbortCampaign)
+		}
+
+		webhooks := api.Group("/webhooks")
+		webhooks.Use(handlers.RequireRole(models.RoleAdmin))
+		{
+			webhooks.POST("", handlers.Audit("webhook.create"), webhookHandler.CreateWebhook)
+			webhooks.GET("", webhookHandler.ListWebhooks)
+			webhooks.GET("/:id", webhookHandler.GetWebhook)
+			webhooks.DELETE("/:id", handlers.Audit("webhook.delete"), webhookHandler.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+			webhooks.POST("/:id/test", handlers.Audit("webhook.test"), webhookHandler.TestWebhook)
 		}
 	}
 
@@ -94,17 +185,154 @@ func main() {
 	log.Printf("Starting Tenant Management API server on %s", addr)
 	log.Printf("Database: %s", cfg.DBPath)
 	log.Printf("Base directory: %s", cfg.BaseDir)
+	if cfg.File != "" {
+		log.Printf("Config file: %s", cfg.File)
+	}
 	log.Println("API endpoints:")
 	log.Println("  GET    /health")
 	log.Println("  POST   /api/tenants")
-	log.Println("  GET    /api/tenants")
+	log.Println("  GET    /api/tenants (?q, status, labels, created_after, created_before, sort, order, cursor)")
 	log.Println("  GET    /api/tenants/:name")
+	log.Println("  PATCH  /api/tenants/:name")
 	log.Println("  DELETE /api/tenants/:name")
 	log.Println("  PUT    /api/tenants/:name/stop")
 	log.Println("  PUT    /api/tenants/:name/start")
+	log.Println("  PUT    /api/tenants/:name/idle-policy")
+	log.Println("  PUT    /api/tenants/:name/domain")
+	log.Println("  POST   /api/tenants/:name/upgrade")
+	log.Println("  POST   /api/tenants/:name/rename")
+	log.Println("  POST   /api/tenants/:name/clone")
+	log.Println("  GET    /api/tenants/:name/health")
+	log.Println("  GET    /api/tenants/:name/events")
+	log.Println("  GET    /api/tenants/:name/exec (WebSocket, admin)")
+	log.Println("  GET    /api/apps")
+	log.Println("  GET    /api/apps/:name")
+	log.Println("  POST   /api/apps (admin)")
+	log.Println("  DELETE /api/apps/:name (admin)")
+	log.Println("  GET    /api/audit (admin, ?format=jsonl to export)")
+	log.Println("  GET    /api/admin/config (admin)")
+	log.Println("  GET    /api/admin/orphans (admin)")
+	log.Println("  DELETE /api/admin/orphans (admin, requires confirm)")
+	log.Println("  POST   /api/admin/images/pull (admin, ?format=jsonl streams progress)")
+	log.Println("  POST   /api/admin/images/load (admin, docker save tarball)")
+	log.Println("  POST   /api/campaigns (admin)")
+	log.Println("  GET    /api/campaigns (admin)")
+	log.Println("  GET    /api/campaigns/:id (admin)")
+	log.Println("  POST   /api/campaigns/:id/pause (admin)")
+	log.Println("  POST   /api/campaigns/:id/resume (admin)")
+	log.Println("  POST   /api/campaigns/:id/abort (admin)")
+	log.Println("  POST   /api/webhooks (admin)")
+	log.Println("  GET    /api/webhooks (admin)")
+	log.Println("  GET    /api/webhooks/:id (admin)")
+	log.Println("  DELETE /api/webhooks/:id (admin)")
+	log.Println("  GET    /api/webhooks/:id/deliveries (admin)")
+	log.Println("  POST   /api/webhooks/:id/test (admin)")
+	if len(cfg.APIKeys) == 0 {
+		log.Println("Warning: no API keys are configured, the API is unauthenticated and admin endpoints are disabled")
+	}
 
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	var handler http.Handler = router
+	if cfg.ProxyEnabled {
+		handler = handlers.NewTenantProxy(tenantService, router)
+		log.Printf("Tenant proxy enabled (mode: %s, base domain: %q)", cfg.ProxyMode, cfg.ProxyBaseDomain)
+	}
+	if cfg.NetworkIsolation && (cfg.ProxyEnabled || cfg.ProbeTarget == "network") {
+		log.Println("Note: with network isolation the manager reaches tenants only if its container is listed in tenant_network_attach")
 	}
+	if !cfg.PublishPorts && cfg.ProbeTarget != "network" {
+		log.Println("Warning: publish_ports is false, set probe_target to network so health probes can reach tenants")
+	}
+
+	tlsConfig, challengeHandler, err := utils.BuildTLSConfig(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig == nil {
+			serverErr <- server.ListenAndServe()
+			return
+		}
+		if challengeHandler != nil && cfg.ACMEHTTPAddr != "" {
+			go func() {
+				log.Printf("Serving ACME HTTP-01 challenges on %s", cfg.ACMEHTTPAddr)
+				if err := http.ListenAndServe(cfg.ACMEHTTPAddr, challengeHandler); err != nil {
+					log.Printf("Warning: ACME challenge listener stopped: %v", err)
+				}
+			}()
+		}
+		log.Println("TLS enabled")
+		serverErr <- server.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Server stopped: %v", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutdown signal received, draining")
+	}
+	stop()
+
+	shutdown(settings.Get().DrainTimeout, server, tenantService, webhookService, dockerClient, &background)
 }
 
+// shutdown stops accepting mutations, lets in-flight tenant operations
+// finish (or rolls them back once the drain timeout expires), stops the
+// HTTP server and background monitors and then closes Docker and the
+// database.
+func shutdown(timeout time.Duration, server *http.Server, tenantService *services.TenantService, webhookService *services.WebhookService, dockerClient *utils.DockerClient, background *sync.WaitGroup) {
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := tenantService.Drain(drainCtx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), timeout)
+	defer cancelServer()
+	if err := server.Shutdown(serverCtx); err != nil {
+		log.Printf("Warning: HTTP server did not shut down cleanly: %v", err)
+	}
+
+	background.Wait()
+
+	webhookCtx, cancelWebhooks := context.WithTimeout(context.Background(), timeout)
+	defer cancelWebhooks()
+	if err := webhookService.Shutdown(webhookCtx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if err := dockerClient.Close(); err != nil {
+		log.Printf("Warning: failed to close Docker client: %v", err)
+	}
+	if err := database.CloseDB(); err != nil {
+		log.Printf("Warning: failed to close database: %v", err)
+	}
+
+	log.Println("Shutdown complete")
+}
+
+// reloadOnSIGHUP re-reads the configuration on SIGHUP. Settings that are
+// only read at startup keep their old value until the next restart.
+func reloadOnSIGHUP(settings *config.Store) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		result, err := settings.Reload()
+		if err != nil {
+			log.Printf("Warning: configuration reload failed, keeping current settings: %v", err)
+			continue
+		}
+		log.Printf("Configuration reloaded: %s", result)
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	AppMountFiles  = "files"
+	AppMountConfig = "config"
+	AppMountVolume = "volume"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type AppMount struct {
+	// Source is "files" or "config" for the tenant directories, or
+	// "volume" for the tenant's settings volume.
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type App struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// EffectiveImage is Image, or docker_image when the template has none.
+	EffectiveImage  string     `json:"effective_image"`
+	InternalPort    int        `json:"internal_port"`
+	Mounts          []AppMount `json:"mounts"`
+	Env             []string   `json:"env"`
+	HealthPath      string     `json:"health_path,omitempty"`
+	BaseURLEnv      string     `json:"base_url_env,omitempty"`
+	CredentialRegex string     `json:"credential_regex,omitempty"`
+	Username        string     `json:"username,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type CreateAppRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	// Image defaults to the configured docker_image.
+	Image        string     `json:"image"`
+	InternalPort int        `json:"internal_port" binding:"required"`
+	Mounts       []AppMount `json:"mounts"`
+	Env          []string   `json:"env"`
+	// HealthPath is probed over HTTP; without one any response counts.
+	HealthPath string `json:"health_path"`
+	// BaseURLEnv names the variable that receives the tenant's path
+	// prefix when the proxy serves tenants under /t/<name>/.
+	BaseURLEnv string `json:"base_url_env"`
+	// CredentialRegex extracts the initial password from the container
+	// logs with its first capture group.
+	CredentialRegex string `json:"credential_regex"`
+	Username        string `json:"username"`
+}
+
+func (r *CreateAppRequest) Validate() error {
+	if err := ValidateTenantName(r.Name); err != nil {
+		return fmt.Errorf("invalid app name: %w", err)
+	}
+	if r.Image != "" {
+		if err := ValidateImageRef(r.Image); err != nil {
+			return err
+		}
+	}
+	if r.InternalPort < 1 || r.InternalPort > 65535 {
+		return fmt.Errorf("internal_port must be between 1 and 65535")
+	}
+
+	targets := make(map[string]bool)
+	for _, mount := range r.Mounts {
+		switch mount.Source {
+		case AppMountFiles, AppMountConfig, AppMountVolume:
+		default:
+			return fmt.Errorf("mount source must be files, config or volume, got %q", mount.Source)
+		}
+		if !path.IsAbs(mount.Target) || path.Clean(mount.Target) == "/" {
+			return fmt.Errorf("mount target must be an absolute path below /, got %q", mount.Target)
+		}
+		if targets[path.Clean(mount.Target)] {
+			return fmt.Errorf("mount target %s is used twice", mount.Target)
+		}
+		targets[path.Clean(mount.Target)] = true
+	}
+
+	for _, entry := range r.Env {
+		name, _, ok := strings.Cut(entry, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			return fmt.Errorf("env entries must look like NAME=value, got %q", entry)
+		}
+	}
+	if r.BaseURLEnv != "" && !envNamePattern.MatchString(r.BaseURLEnv) {
+		return fmt.Errorf("base_url_env must be a variable name, got %q", r.BaseURLEnv)
+	}
+
+	if r.HealthPath != "" && !strings.HasPrefix(r.HealthPath, "/") {
+		return fmt.Errorf("health_path must start with /")
+	}
+
+	if r.CredentialRegex != "" {
+		re, err := regexp.Compile(r.CredentialRegex)
+		if err != nil {
+			return fmt.Errorf("invalid credential_regex: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return fmt.Errorf("credential_regex needs a capture group for the password")
+		}
+	}
+	return nil
+}
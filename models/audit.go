@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DefaultAuditPerPage = 50
+	MaxAuditPerPage     = 500
+)
+
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Role       string          `json:"role,omitempty"`
+	Action     string          `json:"action"`
+	Tenant     string          `json:"tenant,omitempty"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Outcome    string          `json:"outcome"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error,omitempty"`
+	ClientIP   string          `json:"client_ip,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	DurationMs int64           `json:"duration_ms"`
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignCompleted = "completed"
+	// CampaignHalted means the failure threshold was exceeded.
+	CampaignHalted  = "halted"
+	CampaignAborted = "aborted"
+)
+
+const (
+	CampaignTenantPending   = "pending"
+	CampaignTenantUpgrading = "upgrading"
+	CampaignTenantUpgraded  = "upgraded"
+	CampaignTenantUnchanged = "unchanged"
+	// CampaignTenantRolledBack failed its upgrade and runs the previous image.
+	CampaignTenantRolledBack = "rolled_back"
+	CampaignTenantFailed     = "failed"
+	// CampaignTenantUnhealthy was upgraded but failed the wave's health gate.
+	CampaignTenantUnhealthy = "unhealthy"
+	CampaignTenantSkipped   = "skipped"
+)
+
+const (
+	DefaultCampaignCanarySize = 1
+	DefaultCampaignWaveSize   = 10
+	DefaultCampaignHealthGate = 60
+	MaxCampaignWaveSize       = 100
+)
+
+// CampaignSelector picks the tenants of a campaign. Every non-empty field
+// must match; an empty selector picks all tenants in an active status.
+type CampaignSelector struct {
+	Tenants  []string `json:"tenants,omitempty"`
+	Images   []string `json:"images,omitempty"`
+	Statuses []string `json:"statuses,omitempty"`
+	// Labels is a label selector such as "env=prod,tier!=free".
+	Labels string `json:"labels,omitempty"`
+}
+
+type CreateCampaignRequest struct {
+	// Image defaults to the image of each tenant's app.
+	Image       string           `json:"image"`
+	Selector    CampaignSelector `json:"selector"`
+	CanarySize  *int             `json:"canary_size"`
+	WaveSize    *int             `json:"wave_size"`
+	MaxFailures *int             `json:"max_failures"`
+	// HealthGateSeconds is how long a wave must stay healthy before the
+	// next one starts.
+	HealthGateSeconds *int `json:"health_gate_seconds"`
+}
+
+func (r *CreateCampaignRequest) Validate() error {
+	if r.Image != "" {
+		if err := ValidateImageRef(r.Image); err != nil {
+			return err
+		}
+	}
+	for _, name := range r.Selector.Tenants {
+		if err := ValidateTenantName(name); err != nil {
+			return err
+		}
+	}
+	for _, image := range r.Selector.Images {
+		if err := ValidateImageRef(image); err != nil {
+			return err
+		}
+	}
+	for _, status := range r.Selector.Statuses {
+		if !IsValidStatus(status) {
+			return fmt.Errorf("invalid status %q", status)
+		}
+	}
+	if _, err := ParseLabelSelector(r.Selector.Labels); err != nil {
+		return err
+	}
+	if r.CanarySize != nil && *r.CanarySize < 0 {
+		return fmt.Errorf("canary_size must not be negative")
+	}
+	if r.WaveSize != nil && (*r.WaveSize < 1 || *r.WaveSize > MaxCampaignWaveSize) {
+		return fmt.Errorf("wave_size must be between 1 and %d", MaxCampaignWaveSize)
+	}
+	if r.MaxFailures != nil && *r.MaxFailures < 0 {
+		return fmt.Errorf("max_failures must not be negative")
+	}
+	if r.HealthGateSeconds != nil && *r.HealthGateSeconds < 0 {
+		return fmt.Errorf("health_gate_seconds must not be negative")
+	}
+	return nil
+}
+
+type Campaign struct {
+	ID                uint             `json:"id"`
+	Image             string           `json:"image"`
+	Selector          CampaignSelector `json:"selector"`
+	State             string           `json:"state"`
+	CanarySize        int              `json:"canary_size"`
+	WaveSize          int              `json:"wave_size"`
+	MaxFailures       int              `json:"max_failures"`
+	HealthGateSeconds int              `json:"health_gate_seconds"`
+	Wave              int              `json:"wave"`
+	Waves             int              `json:"waves"`
+	Counts            map[string]int   `json:"counts"`
+	Error             string           `json:"error,omitempty"`
+	CreatedBy         string           `json:"created_by,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	FinishedAt        *time.Time       `json:"finished_at,omitempty"`
+	Tenants           []CampaignTenant `json:"tenants,omitempty"`
+}
+
+type CampaignTenant struct {
+	Tenant        string     `json:"tenant"`
+	Wave          int        `json:"wave"`
+	State         string     `json:"state"`
+	PreviousImage string     `json:"previous_image,omitempty"`
+	ImageDigest   string     `json:"image_digest,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthUnknown   = "unknown"
+)
+
+type HealthFailure struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error"`
+}
+
+type TenantHealth struct {
+	Status              string             `json:"status"`
+	ConsecutiveFailures int                `json:"consecutive_failures"`
+	LastCheckedAt       *time.Time         `json:"last_checked_at,omitempty"`
+	LastSuccessAt       *time.Time         `json:"last_success_at,omitempty"`
+	LastFailure         *HealthFailure     `json:"last_failure,omitempty"`
+	Uptime              map[string]float64 `json:"uptime"`
+}
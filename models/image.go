@@ -0,0 +1,33 @@
+package models
+
+type PullImageRequest struct {
+	// Image defaults to the configured docker_image.
+	Image string `json:"image"`
+}
+
+func (r *PullImageRequest) Validate() error {
+	if r.Image == "" {
+		return nil
+	}
+	return ValidateImageRef(r.Image)
+}
+
+type PulledImage struct {
+	Image       string `json:"image"`
+	ImageDigest string `json:"image_digest"`
+}
+
+type LoadedImages struct {
+	Images []string `json:"images"`
+}
+
+// ImagePullEvent is one line of a streamed pull. The last line carries
+// either the pinned digest or the error.
+type ImagePullEvent struct {
+	Status      string `json:"status"`
+	ID          string `json:"id,omitempty"`
+	Current     int64  `json:"current,omitempty"`
+	Total       int64  `json:"total,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	MaxTenantLabels      = 64
+	MaxDescriptionLength = 1024
+)
+
+// Label keys follow the Kubernetes style ("team", "example.com/owner");
+// values must not contain the selector separators "," and "=".
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._/-]{0,61}[a-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^[^,=\s]{0,255}$`)
+)
+
+const (
+	LabelEquals    = "="
+	LabelNotEquals = "!="
+	LabelExists    = "exists"
+)
+
+// LabelRequirement is one term of a label selector such as "env=prod",
+// "tier!=free" or "owner".
+type LabelRequirement struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+func ValidateLabelKey(key string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use lowercase letters, digits, '.', '_', '-' and '/' (max 63)", key)
+	}
+	return nil
+}
+
+func ValidateLabelValue(key, value string) error {
+	if !labelValuePattern.MatchString(value) {
+		return fmt.Errorf("invalid value for label %q: at most 255 characters without spaces, ',' or '='", key)
+	}
+	return nil
+}
+
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxTenantLabels {
+		return fmt.Errorf("a tenant can have at most %d labels", MaxTenantLabels)
+	}
+	for key, value := range labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if err := ValidateLabelValue(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector reads a comma separated selector, e.g.
+// "env=prod,team=ops,tier!=free,owner".
+func ParseLabelSelector(selector string) ([]LabelRequirement, error) {
+	requirements := make([]LabelRequirement, 0)
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		req := LabelRequirement{Key: term, Operator: LabelExists}
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			req = LabelRequirement{Key: key, Operator: LabelNotEquals, Value: value}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			req = LabelRequirement{Key: key, Operator: LabelEquals, Value: strings.TrimPrefix(value, "=")}
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if err := ValidateLabelKey(req.Key); err != nil {
+			return nil, err
+		}
+		if err := ValidateLabelValue(req.Key, req.Value); err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, req)
+	}
+	return requirements, nil
+}
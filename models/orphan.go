@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	OrphanContainer = "container"
+	OrphanVolume    = "volume"
+	OrphanNetwork   = "network"
+	OrphanDirectory = "directory"
+	// OrphanTenant is a database row whose container or directory is gone.
+	OrphanTenant = "tenant"
+)
+
+type Orphan struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Name        string     `json:"name"`
+	Tenant      string     `json:"tenant"`
+	Reason      string     `json:"reason"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	DeletableAt *time.Time `json:"deletable_at,omitempty"`
+}
+
+type OrphanFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+type OrphanCollection struct {
+	Removed []Orphan        `json:"removed"`
+	Failed  []OrphanFailure `json:"failed"`
+}
+
+// CollectOrphansRequest removes the listed orphans, or every reported
+// orphan resource when IDs is empty. Tenant records are only dropped when
+// listed. Confirm must be set explicitly.
+type CollectOrphansRequest struct {
+	Confirm bool     `json:"confirm"`
+	IDs     []string `json:"ids"`
+}
+
+func (r *CollectOrphansRequest) Validate() error {
+	if !r.Confirm {
+		return fmt.Errorf("confirm must be true to delete orphaned resources")
+	}
+	return nil
+}
@@ -18,6 +18,9 @@ type PaginationMeta struct {
 	Page       int `json:"page"`
 	PerPage    int `json:"per_page"`
 	MaxPage int `json:"max_page"`
+	// NextCursor continues the listing after the last item; it is empty on
+	// the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type ErrorResponse struct {
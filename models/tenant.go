@@ -4,38 +4,195 @@ import (
 	"fmt"
 	"regexp"
 	"slices"
+	"strings"
 	"time"
+
+	"github.com/distribution/reference"
 )
 
 type Tenant struct {
-	ID            int       `json:"id"`
-	Name          string    `json:"name"`
-	Port          int       `json:"port"`
-	ContainerName string    `json:"container_name"`
-	VolumeName    string    `json:"volume_name"`
-	Status        string    `json:"status"`
-	URL           string    `json:"url"`
-	Username      string    `json:"username,omitempty"`
-	Password      string    `json:"password,omitempty"` // Only populated when fetched from logs
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	ID                 int               `json:"id"`
+	Name               string            `json:"name"`
+	Port               int               `json:"port"`
+	ContainerName      string            `json:"container_name"`
+	VolumeName         string            `json:"volume_name"`
+	Status             string            `json:"status"`
+	App                string            `json:"app"`
+	Description        string            `json:"description,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	URL                string            `json:"url"`
+	Username           string            `json:"username,omitempty"`
+	Password           string            `json:"password,omitempty"` // Only populated when fetched from logs
+	Health             *TenantHealth     `json:"health,omitempty"`
+	IdleTimeoutMinutes int               `json:"idle_timeout_minutes"`
+	LastActivityAt     *time.Time        `json:"last_activity_at,omitempty"`
+	CustomDomain       *string           `json:"custom_domain,omitempty"`
+	Network            string            `json:"network,omitempty"`
+	Egress             string            `json:"egress,omitempty"`
+	Security           *SecurityProfile  `json:"security,omitempty"`
+	Image              string            `json:"image,omitempty"`
+	ImageDigest        string            `json:"image_digest,omitempty"`
+	MemoryMB           int               `json:"memory_mb"`
+	CPUs               float64           `json:"cpus"`
+	RestartPolicy      string            `json:"restart_policy"`
+	Env                map[string]string `json:"env,omitempty"` // Values are masked
+	ClonedFrom         string            `json:"cloned_from,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	DeletedAt          *time.Time        `json:"deleted_at,omitempty"`
+}
+
+type SecurityProfile struct {
+	UID             int      `json:"uid"`
+	GID             int      `json:"gid"`
+	CapDrop         []string `json:"cap_drop"`
+	NoNewPrivileges bool     `json:"no_new_privileges"`
+	ReadOnlyRootfs  bool     `json:"read_only_rootfs"`
+	SeccompProfile  string   `json:"seccomp_profile,omitempty"`
+	AppArmorProfile string   `json:"apparmor_profile,omitempty"`
 }
 
 type CreateTenantRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name               string `json:"name" binding:"required"`
+	IdleTimeoutMinutes int    `json:"idle_timeout_minutes"`
+	CustomDomain       string `json:"custom_domain"`
+	// App names the catalog template and defaults to "filebrowser".
+	App         string            `json:"app"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	// Image overrides the app's image, e.g.
+	// "filebrowser/filebrowser:v2.31.2" or a reference pinned by digest.
+	Image string `json:"image"`
 }
 
+// UpdateTenantRequest changes only the fields that are present. Labels and
+// env are merged: an entry mapped to null is removed.
 type UpdateTenantRequest struct {
-	Status string `json:"status"`
+	Description *string            `json:"description"`
+	Labels      map[string]*string `json:"labels"`
+	// MemoryMB and CPUs of 0 remove the limit.
+	MemoryMB      *int     `json:"memory_mb"`
+	CPUs          *float64 `json:"cpus"`
+	RestartPolicy *string  `json:"restart_policy"`
+	// ImageTag moves the tenant to another tag of the image it runs.
+	ImageTag *string            `json:"image_tag"`
+	Env      map[string]*string `json:"env"`
 }
 
-func (r *CreateTenantRequest) Validate() error {
-	if r.Name == "" {
+func (r *UpdateTenantRequest) Validate() error {
+	if r.Description != nil && len(*r.Description) > MaxDescriptionLength {
+		return fmt.Errorf("description must not exceed %d characters", MaxDescriptionLength)
+	}
+	for key, value := range r.Labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if value != nil {
+			if err := ValidateLabelValue(key, *value); err != nil {
+				return err
+			}
+		}
+	}
+	if r.MemoryMB != nil && *r.MemoryMB != 0 && (*r.MemoryMB < MinMemoryMB || *r.MemoryMB > MaxMemoryMB) {
+		return fmt.Errorf("memory_mb must be 0 or between %d and %d", MinMemoryMB, MaxMemoryMB)
+	}
+	if r.CPUs != nil && *r.CPUs != 0 && (*r.CPUs < MinCPUs || *r.CPUs > MaxCPUs) {
+		return fmt.Errorf("cpus must be 0 or between %g and %g", MinCPUs, MaxCPUs)
+	}
+	if r.RestartPolicy != nil && !slices.Contains(RestartPolicies, *r.RestartPolicy) {
+		return fmt.Errorf("restart_policy must be one of %s", strings.Join(RestartPolicies, ", "))
+	}
+	if r.ImageTag != nil && !imageTagPattern.MatchString(*r.ImageTag) {
+		return fmt.Errorf("invalid image tag %q", *r.ImageTag)
+	}
+	for name, value := range r.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid env variable name %q", name)
+		}
+		if value != nil && strings.ContainsRune(*value, 0) {
+			return fmt.Errorf("env variable %s contains a NUL byte", name)
+		}
+	}
+	return nil
+}
+
+const (
+	MinMemoryMB  = 16
+	MaxMemoryMB  = 1024 * 1024
+	MinCPUs      = 0.01
+	MaxCPUs      = 512.0
+	MaxTenantEnv = 64
+)
+
+const RestartUnlessStopped = "unless-stopped"
+
+var RestartPolicies = []string{"no", "always", RestartUnlessStopped, "on-failure"}
+
+var imageTagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// UpdateTenantResult tells which changes took effect right away and which
+// wait for the container to be recreated in the background.
+type UpdateTenantResult struct {
+	Tenant  *Tenant  `json:"tenant"`
+	Applied []string `json:"applied"`
+	Pending []string `json:"pending"`
+}
+
+type RenameTenantRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (r *RenameTenantRequest) Validate() error {
+	return ValidateTenantName(r.Name)
+}
+
+// CloneTenantRequest copies a tenant under a new name. With
+// ResetCredentials the clone starts from an empty settings volume, where
+// apps keep their users, and gets a freshly generated login.
+type CloneTenantRequest struct {
+	Name             string `json:"name" binding:"required"`
+	ResetCredentials bool   `json:"reset_credentials"`
+}
+
+func (r *CloneTenantRequest) Validate() error {
+	return ValidateTenantName(r.Name)
+}
+
+type IdlePolicyRequest struct {
+	IdleTimeoutMinutes *int `json:"idle_timeout_minutes" binding:"required"`
+}
+
+type CustomDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+func ValidateDomain(domain string) error {
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		return fmt.Errorf("domain must be a valid lowercase hostname such as files.example.com")
+	}
+	return nil
+}
+
+const MaxIdleTimeoutMinutes = 30 * 24 * 60
+
+func ValidateIdleTimeout(minutes int) error {
+	if minutes < 0 {
+		return fmt.Errorf("idle_timeout_minutes must not be negative")
+	}
+	if minutes > MaxIdleTimeoutMinutes {
+		return fmt.Errorf("idle_timeout_minutes must not exceed %d", MaxIdleTimeoutMinutes)
+	}
+	return nil
+}
+
+func ValidateTenantName(name string) error {
+	if name == "" {
 		return fmt.Errorf("tenant name is required")
 	}
 
-	matched, err := regexp.MatchString("^[a-zA-Z0-9_]+$", r.Name)
+	matched, err := regexp.MatchString("^[a-zA-Z0-9_]+$", name)
 	if err != nil {
 		return fmt.Errorf("failed to validate tenant name format: %w", err)
 	}
@@ -44,26 +201,84 @@ func (r *CreateTenantRequest) Validate() error {
 		return fmt.Errorf("tenant name must contain only alphanumeric characters and underscores")
 	}
 
-	if len(r.Name) < 3 {
+	if len(name) < 3 {
 		return fmt.Errorf("tenant name must be at least 3 characters long")
 	}
 
-	if len(r.Name) > 50 {
+	if len(name) > 50 {
 		return fmt.Errorf("tenant name must not exceed 50 characters")
 	}
 
 	return nil
 }
 
+func (r *CreateTenantRequest) Validate() error {
+	if err := ValidateTenantName(r.Name); err != nil {
+		return err
+	}
+
+	if err := ValidateIdleTimeout(r.IdleTimeoutMinutes); err != nil {
+		return err
+	}
+
+	if r.App != "" {
+		if err := ValidateTenantName(r.App); err != nil {
+			return fmt.Errorf("invalid app name: %w", err)
+		}
+	}
+
+	if len(r.Description) > MaxDescriptionLength {
+		return fmt.Errorf("description must not exceed %d characters", MaxDescriptionLength)
+	}
+
+	if err := ValidateLabels(r.Labels); err != nil {
+		return err
+	}
+
+	if r.CustomDomain != "" {
+		r.CustomDomain = strings.ToLower(r.CustomDomain)
+		if err := ValidateDomain(r.CustomDomain); err != nil {
+			return err
+		}
+	}
+
+	if r.Image != "" {
+		if err := ValidateImageRef(r.Image); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateImageRef accepts Docker image references with an optional tag
+// and/or digest, such as "filebrowser/filebrowser:v2" or "repo@sha256:...".
+func ValidateImageRef(ref string) error {
+	if _, err := reference.ParseNormalizedNamed(ref); err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	return nil
+}
+
 const (
-	StatusRunning = "running"
-	StatusStopped = "stopped"
-	StatusError   = "error"
-	StatusDeleted = "deleted"
+	StatusRunning   = "running"
+	StatusStopped   = "stopped"
+	StatusError     = "error"
+	StatusDeleted   = "deleted"
+	StatusUnhealthy = "unhealthy"
+
+	StatusQuarantined = "quarantined"
+	StatusHibernated  = "hibernated"
+	StatusUpgrading   = "upgrading"
+	StatusRenaming    = "renaming"
 )
 
 func IsValidStatus(status string) bool {
-	validStatuses := []string{StatusRunning, StatusStopped, StatusError, StatusDeleted}
+	validStatuses := []string{StatusRunning, StatusStopped, StatusError, StatusDeleted, StatusUnhealthy, StatusQuarantined, StatusHibernated, StatusUpgrading, StatusRenaming}
 	return slices.Contains(validStatuses, status)
 }
 
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+)
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	SortName      = "name"
+	SortPort      = "port"
+	SortCreatedAt = "created_at"
+	SortStatus    = "status"
+	// SortUsage orders by the last activity seen on the tenant.
+	SortUsage = "usage"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	DefaultTenantsPerPage = 10
+	MaxTenantsPerPage     = 100
+)
+
+// ListTenantsQuery selects a page of tenants. A non-nil Cursor switches
+// from page/offset pagination to keyset pagination, which stays stable
+// while tenants are being added.
+type ListTenantsQuery struct {
+	Page          int
+	PerPage       int
+	Labels        []LabelRequirement
+	Query         string
+	Statuses      []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+	Order         string
+	Cursor        *TenantCursor
+}
+
+// Normalize fills in the defaults and validates the query. Newest tenants
+// and the most recently used ones come first unless an order is given.
+func (q *ListTenantsQuery) Normalize() error {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultTenantsPerPage
+	}
+	if q.PerPage > MaxTenantsPerPage {
+		q.PerPage = MaxTenantsPerPage
+	}
+
+	if q.Sort == "" {
+		q.Sort = SortCreatedAt
+	}
+	if !slices.Contains([]string{SortName, SortPort, SortCreatedAt, SortStatus, SortUsage}, q.Sort) {
+		return fmt.Errorf("sort must be one of name, port, created_at, status or usage")
+	}
+
+	if q.Order == "" {
+		q.Order = OrderAsc
+		if q.Sort == SortCreatedAt || q.Sort == SortUsage {
+			q.Order = OrderDesc
+		}
+	}
+	if q.Order != OrderAsc && q.Order != OrderDesc {
+		return fmt.Errorf("order must be asc or desc")
+	}
+
+	for _, status := range q.Statuses {
+		if !IsValidStatus(status) {
+			return fmt.Errorf("invalid status %q", status)
+		}
+	}
+
+	if q.CreatedAfter != nil && q.CreatedBefore != nil && !q.CreatedAfter.Before(*q.CreatedBefore) {
+		return fmt.Errorf("created_after must be before created_before")
+	}
+
+	if q.Cursor != nil && q.Cursor.ID != 0 && (q.Cursor.Sort != q.Sort || q.Cursor.Order != q.Order) {
+		return fmt.Errorf("cursor was issued for sort=%s&order=%s", q.Cursor.Sort, q.Cursor.Order)
+	}
+	return nil
+}
+
+// TenantCursor is the position after the last tenant of a page: the value
+// of the sort field and the tenant ID as a tie-breaker. The zero value
+// starts at the beginning.
+type TenantCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func (c TenantCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func ParseTenantCursor(value string) (*TenantCursor, error) {
+	cursor := &TenantCursor{}
+	if value == "" {
+		return cursor, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID == 0 {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	return cursor, nil
+}
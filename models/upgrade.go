@@ -0,0 +1,24 @@
+package models
+
+type UpgradeTenantRequest struct {
+	// Image defaults to the image of the tenant's app.
+	Image string `json:"image"`
+}
+
+func (r *UpgradeTenantRequest) Validate() error {
+	if r.Image == "" {
+		return nil
+	}
+	return ValidateImageRef(r.Image)
+}
+
+type UpgradeResult struct {
+	Tenant              string `json:"tenant"`
+	PreviousImage       string `json:"previous_image"`
+	PreviousImageDigest string `json:"previous_image_digest"`
+	Image               string `json:"image"`
+	ImageDigest         string `json:"image_digest"`
+	Changed             bool   `json:"changed"`
+	RolledBack          bool   `json:"rolled_back"`
+	Error               string `json:"error,omitempty"`
+}
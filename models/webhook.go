@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const (
+	EventTenantCreated = "tenant.created"
+	EventTenantDeleted = "tenant.deleted"
+	EventTenantStarted = "tenant.started"
+	EventTenantStopped = "tenant.stopped"
+	EventTenantDown    = "tenant.down"
+	EventWebhookTest   = "webhook.test"
+
+	EventTenantUnhealthy   = "tenant.unhealthy"
+	EventTenantRecovered   = "tenant.recovered"
+	EventTenantCrashLoop   = "tenant.crash_loop"
+	EventTenantRecovery    = "tenant.recovery"
+	EventTenantQuarantined = "tenant.quarantined"
+	EventTenantHibernated  = "tenant.hibernated"
+	EventTenantWoken       = "tenant.woken"
+
+	EventTenantUpgraded      = "tenant.upgraded"
+	EventTenantUpgradeFailed = "tenant.upgrade_failed"
+	EventTenantRenamed       = "tenant.renamed"
+)
+
+var WebhookEventTypes = []string{
+	EventTenantCreated,
+	EventTenantDeleted,
+	EventTenantStarted,
+	EventTenantStopped,
+	EventTenantDown,
+	EventTenantUnhealthy,
+	EventTenantRecovered,
+	EventTenantCrashLoop,
+	EventTenantRecovery,
+	EventTenantQuarantined,
+	EventTenantHibernated,
+	EventTenantWoken,
+	EventTenantUpgraded,
+	EventTenantUpgradeFailed,
+	EventTenantRenamed,
+	EventWebhookTest,
+}
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"` // Only returned on creation
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+func (r *CreateWebhookRequest) Validate() error {
+	parsed, err := url.Parse(r.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	if len(r.Events) == 0 {
+		return fmt.Errorf("at least one event type is required")
+	}
+
+	for _, event := range r.Events {
+		if event != "*" && !slices.Contains(WebhookEventTypes, event) {
+			return fmt.Errorf("unknown event type %q", event)
+		}
+	}
+
+	return nil
+}
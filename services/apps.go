@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"tenant-manager/utils"
+)
+
+// app returns the catalog template of a tenant.
+func (s *TenantService) app(name string) (*database.App, error) {
+	if name == "" {
+		name = database.DefaultApp
+	}
+	if cached, ok := s.apps.Load(name); ok {
+		return cached.(*database.App), nil
+	}
+
+	app, err := database.GetAppByName(name)
+	if err != nil {
+		return nil, err
+	}
+	s.apps.Store(name, app)
+	return app, nil
+}
+
+// appImage is the image new tenants of the app are created from.
+func (s *TenantService) appImage(app *database.App) string {
+	if app.Image != "" {
+		return app.Image
+	}
+	return s.cfg().DockerImage
+}
+
+// internalPort is the port the tenant's app listens on in its container.
+func (s *TenantService) internalPort(dbTenant *database.Tenant) (int, error) {
+	app, err := s.app(dbTenant.App)
+	if err != nil {
+		return 0, err
+	}
+	return app.InternalPort, nil
+}
+
+func (s *TenantService) containerSpec(dbTenant *database.Tenant) (utils.ContainerSpec, error) {
+	app, err := s.app(dbTenant.App)
+	if err != nil {
+		return utils.ContainerSpec{}, err
+	}
+
+	tenantDir := filepath.Join(s.baseDir, "tenants", dbTenant.Name)
+	sources := map[string]string{
+		models.AppMountFiles:  filepath.Join(tenantDir, "files"),
+		models.AppMountConfig: filepath.Join(tenantDir, "config"),
+		models.AppMountVolume: dbTenant.VolumeName,
+	}
+	mounts := make([]utils.Mount, 0)
+	for _, mount := range app.MountList() {
+		mounts = append(mounts, utils.Mount{Source: sources[mount.Source], Target: mount.Target})
+	}
+
+	env := app.EnvList()
+	tenantEnv := dbTenant.EnvMap()
+	for _, name := range slices.Sorted(maps.Keys(tenantEnv)) {
+		env = append(env, name+"="+tenantEnv[name])
+	}
+	if app.BaseURLEnv != "" && s.cfg().ProxyEnabled && s.cfg().ProxyMode != ProxyModeHost {
+		env = append(env, app.BaseURLEnv+"="+tenantPathPrefix(dbTenant.Name))
+	}
+
+	image := tenantImage(dbTenant)
+	if image == "" {
+		image = s.appImage(app)
+	}
+
+	return utils.ContainerSpec{
+		TenantName:   dbTenant.Name,
+		Port:         dbTenant.Port,
+		InternalPort: app.InternalPort,
+		Mounts:       mounts,
+		HealthPath:   app.HealthPath,
+		Env:          env,
+		PublishPort:  s.cfg().PublishPorts,
+		Network:      dbTenant.Network,
+		Security:     s.containerSecurity(dbTenant),
+		Image:        image,
+
+		MemoryMB:      dbTenant.MemoryMB,
+		CPUs:          dbTenant.CPUs,
+		RestartPolicy: dbTenant.RestartPolicy,
+	}, nil
+}
+
+// credentials reads the initial login of the tenant from its logs, for
+// apps that print one.
+func (s *TenantService) credentials(ctx context.Context, dbTenant *database.Tenant) (string, string) {
+	app, err := s.app(dbTenant.App)
+	if err != nil || app.CredentialRegex == "" {
+		return "", ""
+	}
+
+	password, err := s.dockerClient.GetContainerLogs(ctx, dbTenant.ContainerName, app.CredentialRegex)
+	if err != nil {
+		password = ""
+	}
+	return app.Username, password
+}
+
+func (s *TenantService) ListApps() ([]models.App, error) {
+	apps, err := database.GetAllApps()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.App, 0, len(apps))
+	for i := range apps {
+		result = append(result, s.toApp(&apps[i]))
+	}
+	return result, nil
+}
+
+func (s *TenantService) GetApp(name string) (*models.App, error) {
+	app, err := database.GetAppByName(name)
+	if err != nil {
+		return nil, err
+	}
+	result := s.toApp(app)
+	return &result, nil
+}
+
+func (s *TenantService) CreateApp(req models.CreateAppRequest) (*models.App, error) {
+	if _, err := database.GetAppByName(req.Name); err == nil {
+		return nil, fmt.Errorf("app %s already exists", req.Name)
+	}
+
+	app := &database.App{
+		Name:            req.Name,
+		Description:     req.Description,
+		Image:           req.Image,
+		InternalPort:    req.InternalPort,
+		HealthPath:      req.HealthPath,
+		BaseURLEnv:      req.BaseURLEnv,
+		CredentialRegex: req.CredentialRegex,
+		Username:        req.Username,
+	}
+	mounts := make([]database.AppMount, 0, len(req.Mounts))
+	for _, mount := range req.Mounts {
+		mounts = append(mounts, database.AppMount{Source: mount.Source, Target: mount.Target})
+	}
+	app.SetMounts(mounts)
+	env := req.Env
+	if env == nil {
+		env = []string{}
+	}
+	app.SetEnv(env)
+
+	if err := database.CreateApp(app); err != nil {
+		return nil, err
+	}
+
+	result := s.toApp(app)
+	return &result, nil
+}
+
+// DeleteApp removes a template that no tenant uses anymore.
+func (s *TenantService) DeleteApp(name string) error {
+	if name == database.DefaultApp {
+		return fmt.Errorf("app %s is built in and cannot be deleted", name)
+	}
+
+	count, err := database.CountTenantsByApp(name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("app %s is still in use by %d tenant(s)", name, count)
+	}
+
+	if err := database.DeleteApp(name); err != nil {
+		return err
+	}
+	s.apps.Delete(name)
+	return nil
+}
+
+func (s *TenantService) toApp(app *database.App) models.App {
+	mounts := make([]models.AppMount, 0)
+	for _, mount := range app.MountList() {
+		mounts = append(mounts, models.AppMount{Source: mount.Source, Target: mount.Target})
+	}
+
+	return models.App{
+		Name:            app.Name,
+		Description:     app.Description,
+		Image:           app.Image,
+		EffectiveImage:  s.appImage(app),
+		InternalPort:    app.InternalPort,
+		Mounts:          mounts,
+		Env:             app.EnvList(),
+		HealthPath:      app.HealthPath,
+		BaseURLEnv:      app.BaseURLEnv,
+		CredentialRegex: app.CredentialRegex,
+		Username:        app.Username,
+		CreatedAt:       app.CreatedAt,
+	}
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+// CampaignRunner upgrades the tenants of a campaign wave by wave. The
+// canary batch is wave 0; every later wave only starts once the previous
+// one passed its health gate and the campaign is still under its failure
+// threshold. Progress lives in the database, so running campaigns are
+// resumed after a restart.
+type CampaignRunner struct {
+	service *TenantService
+
+	mu      sync.Mutex
+	ctx     context.Context
+	workers map[uint]bool
+	wg      sync.WaitGroup
+}
+
+func NewCampaignRunner(service *TenantService) *CampaignRunner {
+	return &CampaignRunner{
+		service: service,
+		workers: make(map[uint]bool),
+	}
+}
+
+// Run resumes the campaigns that were running when the manager stopped
+// and waits for every worker once ctx is cancelled.
+func (r *CampaignRunner) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+
+	campaigns, err := database.GetCampaignsByState(models.CampaignRunning)
+	if err != nil {
+		log.Printf("Warning: failed to load running campaigns: %v", err)
+	}
+	for _, campaign := range campaigns {
+		log.Printf("Resuming upgrade campaign %d at wave %d", campaign.ID, campaign.Wave)
+		r.start(campaign.ID)
+	}
+
+	<-ctx.Done()
+	r.wg.Wait()
+}
+
+func (r *CampaignRunner) start(id uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Before Run, or during shutdown, the campaign is picked up on the
+	// next start instead.
+	if r.ctx == nil || r.ctx.Err() != nil || r.workers[id] {
+		return
+	}
+	r.workers[id] = true
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			r.mu.Lock()
+			delete(r.workers, id)
+			r.mu.Unlock()
+		}()
+		r.execute(r.ctx, id)
+	}()
+}
+
+func (r *CampaignRunner) CreateCampaign(ctx context.Context, req models.CreateCampaignRequest, actor string) (*models.Campaign, error) {
+	selected, err := r.selectTenants(req.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tenants match the selector")
+	}
+
+	// Pull once up front so a bad image fails the request rather than
+	// the canary.
+	if req.Image != "" {
+		if _, err := r.service.dockerClient.ResolveImage(ctx, req.Image); err != nil {
+			return nil, fmt.Errorf("failed to pull target image: %w", err)
+		}
+	}
+
+	canarySize := intOrDefault(req.CanarySize, models.DefaultCampaignCanarySize)
+	waveSize := intOrDefault(req.WaveSize, models.DefaultCampaignWaveSize)
+	healthGate := intOrDefault(req.HealthGateSeconds, models.DefaultCampaignHealthGate)
+
+	tenants := make([]database.CampaignTenant, 0, len(selected))
+	waves := 0
+	for i, name := range selected {
+		wave := 0
+		if i >= canarySize {
+			wave = (i-canarySize)/waveSize + 1
+		}
+		if canarySize == 0 {
+			wave--
+		}
+		waves = wave + 1
+		tenants = append(tenants, database.CampaignTenant{
+			TenantName: name,
+			Wave:       wave,
+			State:      models.CampaignTenantPending,
+		})
+	}
+
+	selector, err := json.Marshal(req.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode selector: %w", err)
+	}
+
+	campaign := &database.Campaign{
+		Image:       req.Image,
+		Selector:    string(selector),
+		State:       models.CampaignRunning,
+		CanarySize:  min(canarySize, len(selected)),
+		WaveSize:    waveSize,
+		MaxFailures: intOrDefault(req.MaxFailures, 0),
+		HealthGate:  time.Duration(healthGate) * time.Second,
+		Waves:       waves,
+		CreatedBy:   actor,
+	}
+	if err := database.CreateCampaign(campaign, tenants); err != nil {
+		return nil, err
+	}
+
+	r.start(campaign.ID)
+
+	return toCampaign(campaign, tenants, true), nil
+}
+
+// selectTenants returns the names of the tenants matching every non-empty
+// field of the selector.
+func (r *CampaignRunner) selectTenants(selector models.CampaignSelector) ([]string, error) {
+	all, err := database.ListAllTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range selector.Tenants {
+		if !slices.ContainsFunc(all, func(t database.Tenant) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("tenant %s not found", name)
+		}
+	}
+
+	var labelled []string
+	if selector.Labels != "" {
+		requirements, err := models.ParseLabelSelector(selector.Labels)
+		if err != nil {
+			return nil, err
+		}
+		labelled, err = database.GetTenantNamesByLabels(labelRequirements(requirements))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selected := make([]string, 0)
+	for i := range all {
+		tenant := &all[i]
+
+		if len(selector.Tenants) > 0 && !slices.Contains(selector.Tenants, tenant.Name) {
+			continue
+		}
+		if len(selector.Statuses) > 0 {
+			if !slices.Contains(selector.Statuses, tenant.Status) {
+				continue
+			}
+		} else if !isActiveStatus(tenant.Status) {
+			continue
+		}
+		if len(selector.Images) > 0 && !r.matchesImage(tenant, selector.Images) {
+			continue
+		}
+		if selector.Labels != "" && !slices.Contains(labelled, tenant.Name) {
+			continue
+		}
+
+		selected = append(selected, tenant.Name)
+	}
+	return selected, nil
+}
+
+func (r *CampaignRunner) matchesImage(tenant *database.Tenant, images []string) bool {
+	current := []string{r.service.runningImage(tenant), tenant.Image, tenant.ImageDigest}
+	for _, image := range images {
+		if slices.Contains(current, image) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CampaignRunner) execute(ctx context.Context, id uint) {
+	for ctx.Err() == nil {
+		campaign, err := database.GetCampaignByID(id)
+		if err != nil {
+			log.Printf("Warning: campaign %d: %v", id, err)
+			return
+		}
+		if campaign.State != models.CampaignRunning {
+			return
+		}
+
+		if campaign.Wave >= campaign.Waves {
+			r.finish(campaign, models.CampaignCompleted, nil)
+			return
+		}
+
+		if !r.runWave(ctx, campaign) {
+			return
+		}
+
+		tenants, err := database.GetCampaignTenants(id)
+		if err != nil {
+			log.Printf("Warning: campaign %d: %v", id, err)
+			return
+		}
+
+		failures, canaryFailures := 0, 0
+		for _, tenant := range tenants {
+			if isCampaignFailure(tenant.State) {
+				failures++
+				if campaign.CanarySize > 0 && tenant.Wave == 0 {
+					canaryFailures++
+				}
+			}
+		}
+		if canaryFailures > 0 {
+			r.finish(campaign, models.CampaignHalted, fmt.Errorf("%d canary tenant(s) failed", canaryFailures))
+			return
+		}
+		if failures > campaign.MaxFailures {
+			r.finish(campaign, models.CampaignHalted, fmt.Errorf("%d failure(s) exceed max_failures %d", failures, campaign.MaxFailures))
+			return
+		}
+
+		if err := database.UpdateCampaignFields(id, map[string]interface{}{"wave": campaign.Wave + 1}); err != nil {
+			log.Printf("Warning: campaign %d: %v", id, err)
+			return
+		}
+	}
+}
+
+// runWave upgrades the wave's tenants in parallel and applies the health
+// gate. It returns false when the wave did not finish, e.g. on shutdown.
+func (r *CampaignRunner) runWave(ctx context.Context, campaign *database.Campaign) bool {
+	tenants, err := database.GetCampaignTenants(campaign.ID)
+	if err != nil {
+		log.Printf("Warning: campaign %d: %v", campaign.ID, err)
+		return false
+	}
+
+	wave := make([]*database.CampaignTenant, 0)
+	for i := range tenants {
+		if tenants[i].Wave == campaign.Wave {
+			wave = append(wave, &tenants[i])
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, tenant := range wave {
+		if tenant.State != models.CampaignTenantPending && tenant.State != models.CampaignTenantUpgrading {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.upgradeTenant(ctx, campaign, tenant)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	upgraded := make([]*database.CampaignTenant, 0)
+	for _, tenant := range wave {
+		if tenant.State == models.CampaignTenantUpgraded {
+			upgraded = append(upgraded, tenant)
+		}
+	}
+	if len(upgraded) == 0 || campaign.HealthGate <= 0 {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(campaign.HealthGate):
+	}
+
+	for _, tenant := range upgraded {
+		if err := r.checkHealth(ctx, tenant.TenantName); err != nil {
+			r.recordOutcome(tenant, models.CampaignTenantUnhealthy, err)
+		}
+	}
+	return ctx.Err() == nil
+}
+
+func (r *CampaignRunner) checkHealth(ctx context.Context, name string) error {
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return fmt.Errorf("tenant not found: %w", err)
+	}
+	return r.service.waitUntilReady(ctx, dbTenant, r.service.cfg().ProbeTimeout)
+}
+
+func (r *CampaignRunner) upgradeTenant(ctx context.Context, campaign *database.Campaign, tenant *database.CampaignTenant) {
+	resumed := tenant.State == models.CampaignTenantUpgrading
+	if err := database.UpdateCampaignTenant(tenant, map[string]interface{}{"state": models.CampaignTenantUpgrading}); err != nil {
+		log.Printf("Warning: campaign %d: %v", campaign.ID, err)
+		return
+	}
+
+	// A started upgrade is carried through on shutdown; Drain cancels it
+	// only when the drain timeout runs out.
+	result, err := r.service.UpgradeTenant(context.WithoutCancel(ctx), tenant.TenantName, models.UpgradeTenantRequest{Image: campaign.Image})
+	if result != nil {
+		tenant.PreviousImage = result.PreviousImage
+		if tenant.PreviousImage == "" {
+			tenant.PreviousImage = r.service.cfg().DockerImage
+		}
+		tenant.ImageDigest = result.ImageDigest
+	}
+
+	switch {
+	case errors.Is(err, ErrDraining):
+		database.UpdateCampaignTenant(tenant, map[string]interface{}{"state": models.CampaignTenantPending})
+		tenant.State = models.CampaignTenantPending
+	case err != nil && strings.Contains(err.Error(), "must be running"):
+		r.recordOutcome(tenant, models.CampaignTenantSkipped, err)
+	case err != nil && result != nil && result.RolledBack:
+		r.recordOutcome(tenant, models.CampaignTenantRolledBack, err)
+	case err != nil:
+		r.recordOutcome(tenant, models.CampaignTenantFailed, err)
+	case !result.Changed && !resumed:
+		r.recordOutcome(tenant, models.CampaignTenantUnchanged, nil)
+	default:
+		r.recordOutcome(tenant, models.CampaignTenantUpgraded, nil)
+	}
+}
+
+func (r *CampaignRunner) recordOutcome(tenant *database.CampaignTenant, state string, cause error) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"state":          state,
+		"previous_image": tenant.PreviousImage,
+		"image_digest":   tenant.ImageDigest,
+		"error":          "",
+		"finished_at":    &now,
+	}
+	if cause != nil {
+		fields["error"] = cause.Error()
+	}
+	if err := database.UpdateCampaignTenant(tenant, fields); err != nil {
+		log.Printf("Warning: campaign %d: %v", tenant.CampaignID, err)
+	}
+	tenant.State = state
+}
+
+func (r *CampaignRunner) finish(campaign *database.Campaign, state string, cause error) {
+	if ok, err := database.TransitionCampaign(campaign.ID, []string{models.CampaignRunning}, state); err != nil || !ok {
+		return
+	}
+
+	fields := map[string]interface{}{"finished_at": time.Now()}
+	if cause != nil {
+		fields["error"] = cause.Error()
+		log.Printf("Upgrade campaign %d %s: %v", campaign.ID, state, cause)
+	}
+	if err := database.UpdateCampaignFields(campaign.ID, fields); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.SkipCampaignTenants(campaign.ID, models.CampaignTenantPending, models.CampaignTenantSkipped, "campaign "+state); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func isCampaignFailure(state string) bool {
+	return state == models.CampaignTenantFailed ||
+		state == models.CampaignTenantRolledBack ||
+		state == models.CampaignTenantUnhealthy
+}
+
+// PauseCampaign stops the campaign after the wave in progress.
+func (r *CampaignRunner) PauseCampaign(id uint) (*models.Campaign, error) {
+	return r.transition(id, []string{models.CampaignRunning}, models.CampaignPaused)
+}
+
+func (r *CampaignRunner) ResumeCampaign(id uint) (*models.Campaign, error) {
+	campaign, err := r.transition(id, []string{models.CampaignPaused}, models.CampaignRunning)
+	if err != nil {
+		return nil, err
+	}
+	r.start(id)
+	return campaign, nil
+}
+
+// AbortCampaign skips every tenant that has not been started. Upgrades in
+// progress finish or roll back on their own.
+func (r *CampaignRunner) AbortCampaign(id uint) (*models.Campaign, error) {
+	if _, err := r.transition(id, []string{models.CampaignRunning, models.CampaignPaused}, models.CampaignAborted); err != nil {
+		return nil, err
+	}
+
+	if err := database.UpdateCampaignFields(id, map[string]interface{}{"finished_at": time.Now()}); err != nil {
+		return nil, err
+	}
+	if err := database.SkipCampaignTenants(id, models.CampaignTenantPending, models.CampaignTenantSkipped, "campaign aborted"); err != nil {
+		return nil, err
+	}
+
+	return r.GetCampaign(id)
+}
+
+func (r *CampaignRunner) transition(id uint, from []string, state string) (*models.Campaign, error) {
+	campaign, err := database.GetCampaignByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := database.TransitionCampaign(id, from, state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("campaign is %s and cannot be %s", campaign.State, state)
+	}
+
+	return r.GetCampaign(id)
+}
+
+func (r *CampaignRunner) GetCampaign(id uint) (*models.Campaign, error) {
+	campaign, err := database.GetCampaignByID(id)
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := database.GetCampaignTenants(id)
+	if err != nil {
+		return nil, err
+	}
+	return toCampaign(campaign, tenants, true), nil
+}
+
+func (r *CampaignRunner) ListCampaigns() ([]models.Campaign, error) {
+	campaigns, err := database.GetAllCampaigns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Campaign, 0, len(campaigns))
+	for i := range campaigns {
+		tenants, err := database.GetCampaignTenants(campaigns[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *toCampaign(&campaigns[i], tenants, false))
+	}
+	return result, nil
+}
+
+func toCampaign(campaign *database.Campaign, tenants []database.CampaignTenant, withTenants bool) *models.Campaign {
+	result := &models.Campaign{
+		ID:                campaign.ID,
+		Image:             campaign.Image,
+		State:             campaign.State,
+		CanarySize:        campaign.CanarySize,
+		WaveSize:          campaign.WaveSize,
+		MaxFailures:       campaign.MaxFailures,
+		HealthGateSeconds: int(campaign.HealthGate / time.Second),
+		Wave:              campaign.Wave,
+		Waves:             campaign.Waves,
+		Counts:            make(map[string]int),
+		Error:             campaign.Error,
+		CreatedBy:         campaign.CreatedBy,
+		CreatedAt:         campaign.CreatedAt,
+		UpdatedAt:         campaign.UpdatedAt,
+		FinishedAt:        campaign.FinishedAt,
+	}
+	if campaign.Selector != "" {
+		if err := json.Unmarshal([]byte(campaign.Selector), &result.Selector); err != nil {
+			log.Printf("Warning: campaign %d has an unreadable selector: %v", campaign.ID, err)
+		}
+	}
+
+	for _, tenant := range tenants {
+		result.Counts[tenant.State]++
+		if !withTenants {
+			continue
+		}
+		result.Tenants = append(result.Tenants, models.CampaignTenant{
+			Tenant:        tenant.TenantName,
+			Wave:          tenant.Wave,
+			State:         tenant.State,
+			PreviousImage: tenant.PreviousImage,
+			ImageDigest:   tenant.ImageDigest,
+			Error:         tenant.Error,
+			UpdatedAt:     tenant.UpdatedAt,
+			FinishedAt:    tenant.FinishedAt,
+		})
+	}
+
+	return result
+}
+
+func intOrDefault(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
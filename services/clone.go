@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+// CloneTenant copies a tenant into a new one with its own port, container,
+// volume and directories. Files and config are copied while the source
+// keeps running; the source is only stopped while its settings volume is
+// copied. The clone is journaled as a create that also names the source,
+// so the source takes no other operation meanwhile, and a failed or
+// interrupted clone is removed again and the source started back up.
+func (s *TenantService) CloneTenant(ctx context.Context, name string, req models.CloneTenantRequest) (*models.Tenant, error) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	source, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	newName := req.Name
+	if err := s.checkTenantName(newName); err != nil {
+		return nil, err
+	}
+	if _, err := database.GetTenantByName(newName); err == nil {
+		return nil, fmt.Errorf("tenant %s already exists", newName)
+	}
+
+	if !isActiveStatus(source.Status) && source.Status != models.StatusStopped {
+		return nil, fmt.Errorf("tenant must be running or stopped to clone, it is %s", source.Status)
+	}
+
+	labels, err := database.GetTenantLabels(name)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := database.GetNextAvailablePort(s.cfg().PortBase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next available port: %w", err)
+	}
+
+	tenantDir := filepath.Join(s.baseDir, "tenants", newName)
+	sourceDir := filepath.Join(s.baseDir, "tenants", name)
+
+	// The clone runs the exact image of the source but gets its own
+	// UID/GID from the new port; the copied data is handed over to it.
+	now := time.Now()
+	clone := *source
+	clone.ID = 0
+	clone.Name = newName
+	clone.Port = port
+	clone.ContainerName = fmt.Sprintf("files_%s", newName)
+	clone.VolumeName = fmt.Sprintf("%s_settings_vol", newName)
+	clone.Status = models.StatusRunning
+	clone.LastActivityAt = &now
+	clone.CustomDomain = nil
+	clone.Network = ""
+	clone.ClonedFrom = name
+	clone.CreatedAt = time.Time{}
+	clone.UpdatedAt = time.Time{}
+	if s.cfg().NetworkIsolation {
+		clone.Network = tenantNetworkName(newName)
+	}
+	dbTenant := &clone
+	s.applySecurityProfile(dbTenant)
+
+	op := &database.Operation{
+		Kind:          OperationCreate,
+		TenantName:    newName,
+		Step:          stepStarted,
+		Port:          port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		TenantDir:     tenantDir,
+		Network:       dbTenant.Network,
+
+		SourceName:     name,
+		PreviousStatus: source.Status,
+	}
+	check := func() error {
+		current, err := database.GetTenantByName(name)
+		if err != nil {
+			return fmt.Errorf("tenant not found: %w", err)
+		}
+		if current.Status != source.Status {
+			return fmt.Errorf("tenant changed to %s while preparing the clone", current.Status)
+		}
+		return nil
+	}
+	if err := s.reserveOperation(op, check, name, newName); err != nil {
+		return nil, fmt.Errorf("failed to journal tenant clone: %w", err)
+	}
+
+	fail := func(err error) (*models.Tenant, error) {
+		if rollbackErr := s.compensateCreate(ctx, op, err); rollbackErr != nil {
+			log.Printf("Warning: %v", rollbackErr)
+		}
+		return nil, err
+	}
+
+	image := s.runningImage(source)
+
+	if err := s.prepareTenantDirs(dbTenant); err != nil {
+		return fail(err)
+	}
+	for _, dir := range []string{"files", "config"} {
+		from, to := filepath.Join(sourceDir, dir), filepath.Join(tenantDir, dir)
+		if err := s.dockerClient.CopyDirectory(ctx, image, from, to); err != nil {
+			return fail(fmt.Errorf("failed to copy %s directory: %w", dir, err))
+		}
+		if dbTenant.RunAsUID != 0 {
+			if err := s.dockerClient.ChownDirectory(ctx, image, to, dbTenant.RunAsUID, dbTenant.RunAsGID); err != nil {
+				return fail(fmt.Errorf("failed to hand over %s directory: %w", dir, err))
+			}
+		}
+	}
+
+	if err := database.UpdateOperationStep(op, stepDirectories); err != nil {
+		return fail(err)
+	}
+
+	if err := s.setupTenantNetwork(ctx, dbTenant); err != nil {
+		return fail(err)
+	}
+
+	if err := database.UpdateOperationStep(op, stepNetwork); err != nil {
+		return fail(err)
+	}
+
+	if !req.ResetCredentials {
+		if err := s.copySettingsVolume(ctx, op, source, image, dbTenant.VolumeName); err != nil {
+			return fail(err)
+		}
+	}
+
+	spec, err := s.containerSpec(dbTenant)
+	if err != nil {
+		return fail(err)
+	}
+
+	if _, err := s.dockerClient.CreateAndStartContainer(ctx, spec); err != nil {
+		return fail(fmt.Errorf("failed to create container: %w", err))
+	}
+
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return fail(err)
+	}
+
+	if err := database.CreateTenantWithLabels(dbTenant, labels); err != nil {
+		return fail(fmt.Errorf("failed to save tenant to database: %w", err))
+	}
+	s.invalidateDomainCache()
+
+	if err := database.FinishOperation(op, database.OperationCompleted, nil); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		log.Printf("Warning: failed to update Prometheus targets: %v", err)
+	}
+
+	s.recordEvent(models.EventTenantCreated, newName, "Tenant cloned", map[string]interface{}{
+		"port":              port,
+		"container_name":    dbTenant.ContainerName,
+		"cloned_from":       name,
+		"reset_credentials": req.ResetCredentials,
+	})
+
+	return s.GetTenant(ctx, newName)
+}
+
+// copySettingsVolume copies the settings volume of a tenant while its
+// container is stopped, and starts the container again afterwards. The
+// source is recorded as stopped meanwhile; if it cannot be started again
+// the clone's rollback or recovery retries.
+func (s *TenantService) copySettingsVolume(ctx context.Context, op *database.Operation, source *database.Tenant, image, to string) error {
+	if isActiveStatus(source.Status) {
+		if err := s.dockerClient.StopContainer(ctx, source.ContainerName); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", source.Name, err)
+		}
+		if err := database.UpdateTenantStatus(source.Name, models.StatusStopped); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		defer func() {
+			if err := s.releaseCloneSource(ctx, op); err != nil {
+				log.Printf("Warning: failed to restart %s after copying its settings: %v", source.Name, err)
+			}
+		}()
+	}
+
+	if err := s.dockerClient.CopyVolume(ctx, image, source.VolumeName, to); err != nil {
+		return fmt.Errorf("failed to copy settings volume: %w", err)
+	}
+	return nil
+}
+
+// releaseCloneSource starts the source of a clone again if the clone
+// stopped it and did not get to restart it.
+func (s *TenantService) releaseCloneSource(ctx context.Context, op *database.Operation) error {
+	if op.SourceName == "" || !isActiveStatus(op.PreviousStatus) {
+		return nil
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	source, err := database.GetTenantByName(op.SourceName)
+	if err != nil || source.Status != models.StatusStopped {
+		return nil
+	}
+
+	if err := s.dockerClient.StartContainer(ctx, source.ContainerName); err != nil {
+		return err
+	}
+	return database.UpdateTenantStatus(source.Name, op.PreviousStatus)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"io"
+	"tenant-manager/utils"
+
+	"github.com/docker/docker/api/types"
+)
+
+// dockerAPI is the part of utils.DockerClient the services use, so that
+// tests can run them against a fake.
+type dockerAPI interface {
+	CreateAndStartContainer(ctx context.Context, spec utils.ContainerSpec) (string, error)
+	CreateContainer(ctx context.Context, spec utils.ContainerSpec) (string, error)
+	UpdateContainer(ctx context.Context, containerName string, update utils.ContainerUpdate) error
+	StartContainer(ctx context.Context, containerName string) error
+	StopContainer(ctx context.Context, containerName string) error
+	RestartContainer(ctx context.Context, containerName string) error
+	RenameContainer(ctx context.Context, containerName, newName string) error
+	RemoveContainer(ctx context.Context, containerName string) error
+	ContainerExists(ctx context.Context, containerName string) bool
+	InspectContainer(ctx context.Context, containerName string) (string, error)
+	InspectContainerState(ctx context.Context, containerName string) (*utils.ContainerState, error)
+	ContainerNetworkBytes(ctx context.Context, containerName string) (uint64, uint64, error)
+	GetContainerLogs(ctx context.Context, containerName, pattern string) (string, error)
+	ListContainerNames(ctx context.Context, prefix string) ([]string, error)
+
+	RemoveVolume(ctx context.Context, volumeName string) error
+	VolumeExists(ctx context.Context, volumeName string) bool
+	ListVolumeNames(ctx context.Context) ([]string, error)
+	CopyVolume(ctx context.Context, imageName, from, to string) error
+	CopyDirectory(ctx context.Context, imageName, from, to string) error
+	ChownDirectory(ctx context.Context, imageName, path string, uid, gid int) error
+
+	ResolveImage(ctx context.Context, imageName string) (string, error)
+	PullImage(ctx context.Context, imageName string, progress utils.PullProgressFunc) error
+	LoadImage(ctx context.Context, tarball io.Reader) ([]string, error)
+
+	EnsureNetwork(ctx context.Context, name string, internal bool) error
+	ConnectNetwork(ctx context.Context, networkName, containerName string) error
+	RemoveNetwork(ctx context.Context, name string) error
+	ListNetworkNames(ctx context.Context) ([]string, error)
+
+	ExecAttach(ctx context.Context, containerName string, cmd []string, rows, cols uint) (string, types.HijackedResponse, error)
+	ExecResize(ctx context.Context, execID string, rows, cols uint) error
+	ExecExitCode(ctx context.Context, execID string) (int, bool, error)
+}
+
+var _ dockerAPI = (*utils.DockerClient)(nil)
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"tenant-manager/database"
+	"tenant-manager/models"
+)
+
+// recordEvent stores a tenant event and forwards it to webhook subscribers.
+func (s *TenantService) recordEvent(eventType, name, message string, data map[string]interface{}) {
+	event := &database.TenantEvent{
+		TenantName: name,
+		Type:       eventType,
+		Message:    message,
+	}
+	if len(data) > 0 {
+		encoded, _ := json.Marshal(data)
+		event.Data = string(encoded)
+	}
+
+	if err := database.CreateTenantEvent(event); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	s.publish(eventType, name, data)
+}
+
+func (s *TenantService) GetTenantEvents(ctx context.Context, name string, page, perPage int) ([]models.TenantEvent, models.PaginationMeta, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	if _, err := database.GetTenantByName(name); err != nil {
+		return nil, models.PaginationMeta{}, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	dbEvents, total, err := database.GetTenantEvents(name, page, perPage)
+	if err != nil {
+		return nil, models.PaginationMeta{}, fmt.Errorf("failed to get tenant events: %w", err)
+	}
+
+	events := make([]models.TenantEvent, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		event := models.TenantEvent{
+			ID:        int(dbEvent.ID),
+			Tenant:    dbEvent.TenantName,
+			Type:      dbEvent.Type,
+			Message:   dbEvent.Message,
+			CreatedAt: dbEvent.CreatedAt,
+		}
+		if dbEvent.Data != "" {
+			event.Data = json.RawMessage(dbEvent.Data)
+		}
+		events = append(events, event)
+	}
+
+	meta := models.PaginationMeta{
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+		MaxPage: int(math.Ceil(float64(total) / float64(perPage))),
+	}
+
+	return events, meta, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// execExitTimeout bounds how long a finished session waits for the exit
+// code of its process.
+const execExitTimeout = 5 * time.Second
+
+type ExecSession struct {
+	ID           string
+	TenantName   string
+	dockerClient dockerAPI
+	hijacked     types.HijackedResponse
+}
+
+func (e *ExecSession) Read(p []byte) (int, error) {
+	return e.hijacked.Reader.Read(p)
+}
+
+func (e *ExecSession) Write(p []byte) (int, error) {
+	return e.hijacked.Conn.Write(p)
+}
+
+func (e *ExecSession) Resize(ctx context.Context, rows, cols uint) error {
+	return e.dockerClient.ExecResize(ctx, e.ID, rows, cols)
+}
+
+// ExitCode waits for the exec process to exit and returns its exit code.
+// The output stream can end shortly before Docker records the exit.
+func (e *ExecSession) ExitCode(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, execExitTimeout)
+	defer cancel()
+
+	for {
+		exitCode, running, err := e.dockerClient.ExecExitCode(ctx, e.ID)
+		if err != nil || !running {
+			return exitCode, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return -1, fmt.Errorf("exec is still running: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (e *ExecSession) Close() error {
+	e.hijacked.Close()
+	return nil
+}
+
+func (s *TenantService) ExecTenant(ctx context.Context, name string, cmd []string, rows, cols uint) (*ExecSession, error) {
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	status, err := s.dockerClient.InspectContainer(ctx, dbTenant.ContainerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if status != models.StatusRunning {
+		return nil, fmt.Errorf("container is not running")
+	}
+
+	if len(cmd) == 0 {
+		cmd = []string{"/bin/sh"}
+	}
+
+	execID, hijacked, err := s.dockerClient.ExecAttach(ctx, dbTenant.ContainerName, cmd, rows, cols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start exec session: %w", err)
+	}
+
+	return &ExecSession{
+		ID:           execID,
+		TenantName:   name,
+		dockerClient: s.dockerClient,
+		hijacked:     hijacked,
+	}, nil
+}
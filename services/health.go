@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+var uptimeWindows = []struct {
+	label    string
+	duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+func (s *TenantService) GetTenantHealth(ctx context.Context, name string) (*models.TenantHealth, error) {
+	if _, err := database.GetTenantByName(name); err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	return s.healthSummary(name)
+}
+
+func (s *TenantService) healthSummary(name string) (*models.TenantHealth, error) {
+	health := &models.TenantHealth{
+		Status: models.HealthUnknown,
+		Uptime: make(map[string]float64, len(uptimeWindows)),
+	}
+
+	now := time.Now()
+	for _, window := range uptimeWindows {
+		total, successes, err := database.GetHealthCheckCounts(name, now.Add(-window.duration))
+		if err != nil {
+			return nil, err
+		}
+		if total > 0 {
+			health.Uptime[window.label] = float64(successes) / float64(total) * 100
+		}
+	}
+
+	last, err := database.GetLastHealthCheck(name)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return health, nil
+	}
+	health.LastCheckedAt = &last.CheckedAt
+
+	lastSuccess, err := database.GetLastHealthCheckByResult(name, true)
+	if err != nil {
+		return nil, err
+	}
+	if lastSuccess != nil {
+		health.LastSuccessAt = &lastSuccess.CheckedAt
+	}
+
+	lastFailure, err := database.GetLastHealthCheckByResult(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if lastFailure != nil {
+		health.LastFailure = &models.HealthFailure{
+			CheckedAt:  lastFailure.CheckedAt,
+			StatusCode: lastFailure.StatusCode,
+			Error:      lastFailure.Error,
+		}
+	}
+
+	health.ConsecutiveFailures, err = s.consecutiveFailures(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if last.Success {
+		health.Status = models.HealthHealthy
+	} else {
+		health.Status = models.HealthUnhealthy
+	}
+
+	return health, nil
+}
+
+// healthURL is the app's health endpoint as reachable from the manager.
+func (s *TenantService) healthURL(dbTenant *database.Tenant) (string, error) {
+	app, err := s.app(dbTenant.App)
+	if err != nil {
+		return "", err
+	}
+
+	return "http://" + s.appAddr(dbTenant, app) + app.HealthPath, nil
+}
+
+// appAddr is the host:port the manager reaches the tenant's app on: the
+// container over the Docker network, or its published port otherwise.
+func (s *TenantService) appAddr(dbTenant *database.Tenant, app *database.App) string {
+	if s.cfg().ProbeTarget == "network" {
+		return net.JoinHostPort(dbTenant.ContainerName, strconv.Itoa(app.InternalPort))
+	}
+	return net.JoinHostPort(s.cfg().ProbeHost, strconv.Itoa(dbTenant.Port))
+}
+
+func (s *TenantService) consecutiveFailures(name string) (int, error) {
+	lastSuccess, err := database.GetLastHealthCheckByResult(name, true)
+	if err != nil {
+		return 0, err
+	}
+
+	since := time.Time{}
+	if lastSuccess != nil {
+		since = lastSuccess.CheckedAt
+	}
+
+	return database.CountFailuresSince(name, since)
+}
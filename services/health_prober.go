@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"tenant-manager/config"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+// HealthProber periodically checks the health endpoint of every
+// running tenant and flips tenants between running and unhealthy.
+type HealthProber struct {
+	service          *TenantService
+	settings         *config.Store
+	httpClient       *http.Client
+	interval         time.Duration
+	failureThreshold int
+	retention        time.Duration
+}
+
+func NewHealthProber(service *TenantService, settings *config.Store) *HealthProber {
+	p := &HealthProber{
+		service:    service,
+		settings:   settings,
+		httpClient: &http.Client{},
+	}
+	p.applySettings()
+	return p
+}
+
+// applySettings picks up reloaded probe settings. It is called between
+// rounds so a round always runs with one consistent set of values.
+func (p *HealthProber) applySettings() {
+	cfg := p.settings.Get()
+	p.httpClient.Timeout = cfg.ProbeTimeout
+	p.interval = cfg.ProbeInterval
+	p.failureThreshold = cfg.ProbeFailureThreshold
+	p.retention = cfg.ProbeRetention
+}
+
+func (p *HealthProber) Run(ctx context.Context) {
+	for {
+		p.applySettings()
+		p.ProbeAll(ctx)
+
+		if err := database.PruneHealthChecks(time.Now().Add(-p.retention)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+func (p *HealthProber) ProbeAll(ctx context.Context) {
+	tenants, err := database.GetTenantsByStatus(models.StatusRunning, models.StatusUnhealthy)
+	if err != nil {
+		log.Printf("Warning: health prober failed to list tenants: %v", err)
+		return
+	}
+
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		p.probeTenant(ctx, tenant)
+	}
+}
+
+func (p *HealthProber) probeTenant(ctx context.Context, tenant database.Tenant) {
+	check := p.probe(ctx, tenant)
+	if err := database.CreateHealthCheck(check); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	if check.Success {
+		if tenant.Status == models.StatusUnhealthy && p.swapStatus(tenant.Name, models.StatusUnhealthy, models.StatusRunning) {
+			p.service.recordEvent(models.EventTenantRecovered, tenant.Name, "Health probes are passing again", nil)
+		}
+		return
+	}
+
+	if tenant.Status != models.StatusRunning {
+		return
+	}
+
+	failures, err := p.service.consecutiveFailures(tenant.Name)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	if failures >= p.failureThreshold && p.swapStatus(tenant.Name, models.StatusRunning, models.StatusUnhealthy) {
+		p.service.recordEvent(models.EventTenantUnhealthy, tenant.Name, "Health probes are failing", map[string]interface{}{
+			"consecutive_failures": failures,
+			"error":                check.Error,
+		})
+	}
+}
+
+// swapStatus applies a status change only if the tenant still has the
+// status it was probed in; a stop, hibernate or upgrade that landed while
+// the probe was in flight wins.
+func (p *HealthProber) swapStatus(name, from, to string) bool {
+	swapped, err := database.SwapTenantStatus(name, from, to)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return swapped
+}
+
+func (p *HealthProber) probe(ctx context.Context, tenant database.Tenant) *database.HealthCheck {
+	check := &database.HealthCheck{
+		TenantName: tenant.Name,
+		CheckedAt:  time.Now(),
+	}
+
+	healthURL, err := p.service.healthURL(&tenant)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	resp, err := p.httpClient.Do(req)
+	check.LatencyMs = time.Since(check.CheckedAt).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	check.StatusCode = resp.StatusCode
+	check.Success = resp.StatusCode >= 200 && resp.StatusCode < 400
+	if !check.Success {
+		check.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+
+	return check
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"tenant-manager/config"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+type trafficSample struct {
+	bytes      uint64
+	observedAt time.Time
+}
+
+// IdleMonitor stops tenants whose idle policy has expired. Activity is
+// taken from the container's network counters (ignoring small amounts
+// such as health probes) and from explicit touches by the manager.
+type IdleMonitor struct {
+	service          *TenantService
+	settings         *config.Store
+	interval         time.Duration
+	trafficThreshold uint64
+
+	mu      sync.Mutex
+	traffic map[string]trafficSample
+}
+
+func NewIdleMonitor(service *TenantService, settings *config.Store) *IdleMonitor {
+	m := &IdleMonitor{
+		service:  service,
+		settings: settings,
+		traffic:  make(map[string]trafficSample),
+	}
+	m.applySettings()
+	return m
+}
+
+func (m *IdleMonitor) applySettings() {
+	cfg := m.settings.Get()
+	m.interval = cfg.IdleCheckInterval
+	m.trafficThreshold = uint64(cfg.IdleTrafficThreshold)
+}
+
+func (m *IdleMonitor) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.interval):
+			m.applySettings()
+			m.CheckAll(ctx)
+		}
+	}
+}
+
+func (m *IdleMonitor) CheckAll(ctx context.Context) {
+	tenants, err := database.GetTenantsByStatus(models.StatusRunning)
+	if err != nil {
+		log.Printf("Warning: idle monitor failed to list tenants: %v", err)
+		return
+	}
+
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		if tenant.IdleTimeoutMinutes <= 0 {
+			continue
+		}
+		m.checkTenant(ctx, tenant)
+	}
+}
+
+func (m *IdleMonitor) checkTenant(ctx context.Context, tenant database.Tenant) {
+	rx, tx, err := m.service.dockerClient.ContainerNetworkBytes(ctx, tenant.ContainerName)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	total := rx + tx
+
+	m.mu.Lock()
+	previous, seen := m.traffic[tenant.Name]
+	m.traffic[tenant.Name] = trafficSample{bytes: total, observedAt: now}
+	m.mu.Unlock()
+
+	if !seen || total < previous.bytes || total-previous.bytes >= m.trafficThreshold {
+		m.service.TouchTenant(tenant.Name)
+		return
+	}
+
+	lastActivity := now
+	if tenant.LastActivityAt != nil {
+		lastActivity = *tenant.LastActivityAt
+	}
+
+	idleFor := now.Sub(lastActivity)
+	if idleFor < time.Duration(tenant.IdleTimeoutMinutes)*time.Minute {
+		return
+	}
+
+	if err := m.service.HibernateTenant(ctx, tenant.Name); err != nil {
+		log.Printf("Warning: failed to hibernate tenant %s: %v", tenant.Name, err)
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.traffic, tenant.Name)
+	m.mu.Unlock()
+}
+
+// TouchTenant records activity for the tenant.
+func (s *TenantService) TouchTenant(name string) {
+	if err := database.UpdateTenantFields(name, map[string]interface{}{"last_activity_at": time.Now()}); err != nil {
+		log.Printf("Warning: failed to record activity for %s: %v", name, err)
+	}
+}
+
+func (s *TenantService) UpdateIdlePolicy(ctx context.Context, name string, minutes int) error {
+	if _, err := database.GetTenantByName(name); err != nil {
+		return fmt.Errorf("tenant not found: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"idle_timeout_minutes": minutes,
+		"last_activity_at":     time.Now(),
+	}
+	if err := database.UpdateTenantFields(name, fields); err != nil {
+		return fmt.Errorf("failed to update idle policy: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TenantService) HibernateTenant(ctx context.Context, name string) error {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := s.lockIdleTenant(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return fmt.Errorf("tenant not found: %w", err)
+	}
+
+	if dbTenant.Status != models.StatusRunning {
+		return fmt.Errorf("container is not running")
+	}
+
+	if err := s.dockerClient.StopContainer(ctx, dbTenant.ContainerName); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	if err := database.UpdateTenantStatus(name, models.StatusHibernated); err != nil {
+		return fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	dbTenant.Status = models.StatusHibernated
+	if err := s.armWakeListener(dbTenant); err != nil {
+		log.Printf("Warning: tenant %s hibernated without a wake listener: %v", name, err)
+	}
+
+	s.recordEvent(models.EventTenantHibernated, name, "Tenant hibernated after inactivity", map[string]interface{}{
+		"idle_timeout_minutes": dbTenant.IdleTimeoutMinutes,
+	})
+
+	return nil
+}
+
+// WakeTenant starts a hibernated tenant and blocks until its app
+// answers its health endpoint. Concurrent callers share a single wake-up.
+func (s *TenantService) WakeTenant(ctx context.Context, name string) error {
+	s.wakeMu.Lock()
+	if done, ok := s.wakeInFlight[name]; ok {
+		s.wakeMu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		dbTenant, err := database.GetTenantByName(name)
+		if err != nil {
+			return fmt.Errorf("tenant not found: %w", err)
+		}
+		if dbTenant.Status != models.StatusRunning {
+			return fmt.Errorf("tenant failed to wake")
+		}
+		return nil
+	}
+
+	ctx, finish, err := s.beginOperation(ctx)
+	if err != nil {
+		s.wakeMu.Unlock()
+		return err
+	}
+	defer finish()
+
+	done := make(chan struct{})
+	s.wakeInFlight[name] = done
+	s.wakeMu.Unlock()
+
+	defer func() {
+		s.wakeMu.Lock()
+		delete(s.wakeInFlight, name)
+		s.wakeMu.Unlock()
+		close(done)
+	}()
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return fmt.Errorf("tenant not found: %w", err)
+	}
+
+	if dbTenant.Status == models.StatusRunning {
+		return nil
+	}
+	if dbTenant.Status != models.StatusHibernated {
+		return fmt.Errorf("tenant is not hibernated")
+	}
+
+	unlock, err := s.lockIdleTenant(name)
+	if err != nil {
+		return err
+	}
+
+	s.disarmWakeListener(name)
+
+	startedAt := time.Now()
+	if err := s.dockerClient.StartContainer(ctx, dbTenant.ContainerName); err != nil {
+		s.armWakeListener(dbTenant)
+		unlock()
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"status":           models.StatusRunning,
+		"last_activity_at": time.Now(),
+	}
+	err = database.UpdateTenantFields(name, fields)
+	unlock()
+	if err != nil {
+		return fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	if err := s.waitUntilReady(ctx, dbTenant, s.cfg().WakeTimeout); err != nil {
+		return err
+	}
+
+	s.recordEvent(models.EventTenantWoken, name, "Tenant woken by incoming request", map[string]interface{}{
+		"wake_ms": time.Since(startedAt).Milliseconds(),
+	})
+
+	return nil
+}
+
+func (s *TenantService) waitUntilReady(ctx context.Context, dbTenant *database.Tenant, timeout time.Duration) error {
+	healthURL, err := s.healthURL(dbTenant)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build readiness request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("tenant did not become ready within %s", timeout)
+}
+
+// armWakeListener binds the hibernated tenant's host port. Every
+// connection it accepts waits for a shared wake-up and is then piped
+// through to the app once it is ready. The port itself is only released
+// right before the container is started, which needs it for its own
+// binding; connections accepted until then are held rather than refused.
+// Without published ports the reverse proxy wakes tenants instead.
+func (s *TenantService) armWakeListener(dbTenant *database.Tenant) error {
+	if !s.cfg().PublishPorts {
+		return nil
+	}
+
+	s.wakeMu.Lock()
+	defer s.wakeMu.Unlock()
+
+	if _, ok := s.wakeListeners[dbTenant.Name]; ok {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", dbTenant.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", dbTenant.Port, err)
+	}
+	s.wakeListeners[dbTenant.Name] = listener
+
+	go s.serveWakeListener(dbTenant.Name, listener)
+	return nil
+}
+
+func (s *TenantService) disarmWakeListener(name string) {
+	s.wakeMu.Lock()
+	defer s.wakeMu.Unlock()
+
+	if listener, ok := s.wakeListeners[name]; ok {
+		listener.Close()
+		delete(s.wakeListeners, name)
+	}
+}
+
+func (s *TenantService) serveWakeListener(name string, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.wakeAndPipe(name, conn)
+	}
+}
+
+// wakeAndPipe wakes the tenant for a connection taken by its wake
+// listener and then pipes the connection to the woken app, dialling it
+// the same way the health prober does.
+func (s *TenantService) wakeAndPipe(name string, conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg().WakeTimeout+30*time.Second)
+	defer cancel()
+
+	if err := s.WakeTenant(ctx, name); err != nil {
+		log.Printf("Warning: failed to wake tenant %s: %v", name, err)
+		return
+	}
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		log.Printf("Warning: failed to reach woken tenant %s: %v", name, err)
+		return
+	}
+	app, err := s.app(dbTenant.App)
+	if err != nil {
+		log.Printf("Warning: failed to reach woken tenant %s: %v", name, err)
+		return
+	}
+
+	upstream, err := net.Dial("tcp", s.appAddr(dbTenant, app))
+	if err != nil {
+		log.Printf("Warning: failed to reach woken tenant %s: %v", name, err)
+		return
+	}
+
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+}
+
+// RestoreWakeListeners re-arms listeners for tenants that were hibernated
+// when the manager last stopped.
+func (s *TenantService) RestoreWakeListeners() {
+	tenants, err := database.GetTenantsByStatus(models.StatusHibernated)
+	if err != nil {
+		log.Printf("Warning: failed to list hibernated tenants: %v", err)
+		return
+	}
+
+	for i := range tenants {
+		if err := s.armWakeListener(&tenants[i]); err != nil {
+			log.Printf("Warning: failed to arm wake listener for %s: %v", tenants[i].Name, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"io"
+	"tenant-manager/models"
+	"tenant-manager/utils"
+)
+
+// PullImage pulls the image even when it is present, so operators can
+// refresh a tag or warm a host before creating tenants, and pins it.
+func (s *TenantService) PullImage(ctx context.Context, req models.PullImageRequest, progress utils.PullProgressFunc) (*models.PulledImage, error) {
+	image := req.Image
+	if image == "" {
+		image = s.cfg().DockerImage
+	}
+
+	if err := s.dockerClient.PullImage(ctx, image, progress); err != nil {
+		return nil, err
+	}
+
+	digest, err := s.dockerClient.ResolveImage(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PulledImage{Image: image, ImageDigest: digest}, nil
+}
+
+// LoadImage imports an image tarball for hosts without registry access.
+func (s *TenantService) LoadImage(ctx context.Context, tarball io.Reader) (*models.LoadedImages, error) {
+	images, err := s.dockerClient.LoadImage(ctx, tarball)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoadedImages{Images: images}, nil
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"tenant-manager/database"
+	"tenant-manager/models"
+)
+
+func labelRequirements(requirements []models.LabelRequirement) []database.LabelRequirement {
+	result := make([]database.LabelRequirement, 0, len(requirements))
+	for _, req := range requirements {
+		result = append(result, database.LabelRequirement{Key: req.Key, Operator: req.Operator, Value: req.Value})
+	}
+	return result
+}
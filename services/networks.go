@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"tenant-manager/database"
+)
+
+const (
+	tenantNetworkPrefix = "tenant_"
+	tenantNetworkSuffix = "_net"
+)
+
+func tenantNetworkName(name string) string {
+	return tenantNetworkPrefix + name + tenantNetworkSuffix
+}
+
+// setupTenantNetwork creates the tenant's own network and connects the
+// containers that need to reach it. Tenants on the shared network have
+// nothing to set up.
+func (s *TenantService) setupTenantNetwork(ctx context.Context, dbTenant *database.Tenant) error {
+	if dbTenant.Network == "" {
+		return nil
+	}
+
+	if err := s.dockerClient.EnsureNetwork(ctx, dbTenant.Network, dbTenant.Egress == "deny"); err != nil {
+		return fmt.Errorf("failed to set up tenant network: %w", err)
+	}
+	s.attachToNetwork(ctx, dbTenant.Network)
+	return nil
+}
+
+func (s *TenantService) attachToNetwork(ctx context.Context, networkName string) {
+	for _, containerName := range s.cfg().TenantNetworkAttach {
+		if err := s.dockerClient.ConnectNetwork(ctx, networkName, containerName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// ReconnectTenantNetworks makes sure every isolated tenant network exists
+// and has the configured containers attached, for example after the
+// monitoring stack or the manager container was recreated.
+func (s *TenantService) ReconnectTenantNetworks(ctx context.Context) {
+	tenants, err := database.ListAllTenants()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	for i := range tenants {
+		if err := s.setupTenantNetwork(ctx, &tenants[i]); err != nil {
+			log.Printf("Warning: tenant %s: %v", tenants[i].Name, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"tenant-manager/database"
+	"tenant-manager/models"
+)
+
+const (
+	OperationCreate = "create"
+	OperationDelete = "delete"
+)
+
+// Journal steps. Each names the last step an operation completed.
+const (
+	stepStarted     = "started"
+	stepDirectories = "directories"
+	stepNetwork     = "network"
+	stepContainer   = "container"
+	stepVolume      = "volume"
+	stepRecord      = "record"
+)
+
+// startOperation journals a create or delete. A delete that is already
+// pending is returned so that it can be carried through again.
+func (s *TenantService) startOperation(kind string, dbTenant *database.Tenant, tenantDir string) (*database.Operation, error) {
+	unlock := s.lockTenants(dbTenant.Name)
+	defer unlock()
+
+	if pending, err := database.GetPendingOperation(dbTenant.Name); err != nil {
+		return nil, err
+	} else if pending != nil && (pending.Kind != kind || kind != OperationDelete) {
+		return nil, fmt.Errorf("tenant has a pending %s operation", pending.Kind)
+	} else if pending != nil {
+		return pending, nil
+	}
+
+	op := &database.Operation{
+		Kind:          kind,
+		TenantName:    dbTenant.Name,
+		Step:          stepStarted,
+		Port:          dbTenant.Port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		TenantDir:     tenantDir,
+		Network:       dbTenant.Network,
+	}
+	if err := database.CreateOperation(op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// reserveOperation journals op once no other operation is pending for the
+// given tenants and check, if set, still passes. Both run under the tenant
+// locks, so two requests cannot pass the check together; once the journal
+// row is written the tenants are busy until it is finished.
+func (s *TenantService) reserveOperation(op *database.Operation, check func() error, names ...string) error {
+	unlock := s.lockTenants(names...)
+	defer unlock()
+
+	if err := checkNoPendingOperation(names...); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return database.CreateOperation(op)
+}
+
+// lockIdleTenant locks a tenant for an action that is not journaled, such
+// as a start or stop, and fails while a journaled operation is under way.
+func (s *TenantService) lockIdleTenant(name string) (func(), error) {
+	unlock := s.lockTenants(name)
+	if err := checkNoPendingOperation(name); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// lockTenants locks the given tenants and returns the function that
+// unlocks them. Names are locked in order so that operations spanning two
+// tenants cannot deadlock.
+func (s *TenantService) lockTenants(names ...string) func() {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	locks := make([]*sync.Mutex, 0, len(sorted))
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		mu, _ := s.tenantLocks.LoadOrStore(name, &sync.Mutex{})
+		locks = append(locks, mu.(*sync.Mutex))
+	}
+
+	for _, mu := range locks {
+		mu.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+func checkNoPendingOperation(names ...string) error {
+	for _, name := range names {
+		pending, err := database.GetPendingOperation(name)
+		if err != nil {
+			return err
+		}
+		if pending == nil {
+			continue
+		}
+		if len(names) == 1 {
+			return fmt.Errorf("tenant has a pending %s operation", pending.Kind)
+		}
+		return fmt.Errorf("tenant %s has a pending %s operation", name, pending.Kind)
+	}
+	return nil
+}
+
+// compensateCreate undoes a create that did not reach the database. It
+// runs even when the operation was cancelled by shutdown; if cleanup
+// fails the operation stays pending and is retried on the next start.
+func (s *TenantService) compensateCreate(ctx context.Context, op *database.Operation, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	if err := s.removeTenantResources(ctx, op); err != nil {
+		database.RecordOperationError(op, err)
+		return fmt.Errorf("failed to roll back tenant %s: %w", op.TenantName, err)
+	}
+	if err := s.releaseCloneSource(ctx, op); err != nil {
+		database.RecordOperationError(op, err)
+		return fmt.Errorf("failed to restart clone source %s: %w", op.SourceName, err)
+	}
+
+	return database.FinishOperation(op, database.OperationCompensated, cause)
+}
+
+// runDelete removes the container, volume, directory and database rows of
+// a tenant. Every step tolerates the resource being gone already, so an
+// interrupted delete can simply be run again.
+func (s *TenantService) runDelete(ctx context.Context, op *database.Operation) error {
+	s.disarmWakeListener(op.TenantName)
+
+	if err := s.removeTenantResources(ctx, op); err != nil {
+		database.RecordOperationError(op, err)
+		return err
+	}
+
+	if _, err := database.GetTenantByName(op.TenantName); err == nil {
+		if err := database.DeleteTenant(op.TenantName); err != nil {
+			database.RecordOperationError(op, err)
+			return fmt.Errorf("failed to delete tenant from database: %w", err)
+		}
+		s.invalidateDomainCache()
+
+		s.publish(models.EventTenantDeleted, op.TenantName, map[string]interface{}{
+			"port": op.Port,
+		})
+	}
+
+	if err := database.DeleteHealthChecks(op.TenantName); err != nil {
+		fmt.Printf("Warning: failed to remove health history: %v\n", err)
+	}
+
+	if err := database.DeleteTenantEvents(op.TenantName); err != nil {
+		fmt.Printf("Warning: failed to remove tenant events: %v\n", err)
+	}
+
+	if err := database.UpdateOperationStep(op, stepRecord); err != nil {
+		return err
+	}
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		fmt.Printf("Warning: failed to update Prometheus targets: %v\n", err)
+	}
+
+	return database.FinishOperation(op, database.OperationCompleted, nil)
+}
+
+func (s *TenantService) removeTenantResources(ctx context.Context, op *database.Operation) error {
+	if s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+		if err := s.dockerClient.RemoveContainer(ctx, op.ContainerName); err != nil {
+			return err
+		}
+	}
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return err
+	}
+
+	if op.Network != "" {
+		if err := s.dockerClient.RemoveNetwork(ctx, op.Network); err != nil {
+			return err
+		}
+		if err := database.UpdateOperationStep(op, stepNetwork); err != nil {
+			return err
+		}
+	}
+
+	if s.dockerClient.VolumeExists(ctx, op.VolumeName) {
+		if err := s.dockerClient.RemoveVolume(ctx, op.VolumeName); err != nil {
+			return err
+		}
+	}
+	if err := database.UpdateOperationStep(op, stepVolume); err != nil {
+		return err
+	}
+
+	if op.TenantDir != "" {
+		if err := os.RemoveAll(op.TenantDir); err != nil {
+			return fmt.Errorf("failed to remove tenant directory: %w", err)
+		}
+	}
+	return database.UpdateOperationStep(op, stepDirectories)
+}
+
+// RecoverOperations finishes the journal left by a previous run. Creates
+// that reached the database are kept, earlier ones are rolled back;
+// deletes are always carried through, and upgrades and renames are kept
+// only when the tenant already records the new image or name. Recovery
+// recreations keep a replacement that was created and otherwise put the
+// old container back.
+func (s *TenantService) RecoverOperations(ctx context.Context) {
+	ops, err := database.GetPendingOperations()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	for i := range ops {
+		op := &ops[i]
+		log.Printf("Recovering interrupted %s of tenant %s (last step: %s)", op.Kind, op.TenantName, op.Step)
+
+		switch op.Kind {
+		case OperationCreate:
+			if _, err := database.GetTenantByName(op.TenantName); err == nil {
+				err = s.releaseCloneSource(ctx, op)
+				if err == nil {
+					err = database.FinishOperation(op, database.OperationCompleted, nil)
+				}
+				if err == nil {
+					err = s.UpdatePrometheusTargets()
+				}
+				if err != nil {
+					log.Printf("Warning: failed to complete create of %s: %v", op.TenantName, err)
+				}
+				continue
+			}
+			if err := s.compensateCreate(ctx, op, fmt.Errorf("interrupted after step %s", op.Step)); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		case OperationDelete:
+			if err := s.runDelete(ctx, op); err != nil {
+				log.Printf("Warning: failed to complete delete of %s: %v", op.TenantName, err)
+			}
+		case OperationUpgrade:
+			if err := s.recoverUpgrade(ctx, op); err != nil {
+				log.Printf("Warning: failed to recover upgrade of %s: %v", op.TenantName, err)
+			}
+		case OperationRename:
+			if err := s.recoverRename(ctx, op); err != nil {
+				log.Printf("Warning: failed to recover rename of %s: %v", op.TenantName, err)
+			}
+		case OperationRecreate:
+			if err := s.recoverRecreate(ctx, op); err != nil {
+				log.Printf("Warning: failed to recover recreation of %s: %v", op.TenantName, err)
+			}
+		default:
+			log.Printf("Warning: unknown operation kind %q for tenant %s", op.Kind, op.TenantName)
+		}
+	}
+}
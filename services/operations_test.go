@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"tenant-manager/config"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"tenant-manager/utils"
+	"testing"
+	"time"
+)
+
+// fakeDocker keeps containers, volumes and networks in memory. Methods the
+// journal does not use are left to the embedded interface and panic.
+type fakeDocker struct {
+	dockerAPI
+
+	mu         sync.Mutex
+	containers map[string]bool // name to running
+	volumes    map[string]bool
+	networks   map[string]bool
+	copies     []string
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		containers: make(map[string]bool),
+		volumes:    make(map[string]bool),
+		networks:   make(map[string]bool),
+	}
+}
+
+func (f *fakeDocker) ContainerExists(ctx context.Context, containerName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.containers[containerName]
+	return ok
+}
+
+func (f *fakeDocker) CreateContainer(ctx context.Context, spec utils.ContainerSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	containerName := fmt.Sprintf("files_%s", spec.TenantName)
+	if _, ok := f.containers[containerName]; ok {
+		return "", fmt.Errorf("container %s already exists", containerName)
+	}
+	f.containers[containerName] = false
+	f.volumes[fmt.Sprintf("%s_settings_vol", spec.TenantName)] = true
+	return containerName, nil
+}
+
+func (f *fakeDocker) CreateAndStartContainer(ctx context.Context, spec utils.ContainerSpec) (string, error) {
+	containerName, err := f.CreateContainer(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	return containerName, f.StartContainer(ctx, containerName)
+}
+
+func (f *fakeDocker) StartContainer(ctx context.Context, containerName string) error {
+	return f.setRunning(containerName, true)
+}
+
+func (f *fakeDocker) StopContainer(ctx context.Context, containerName string) error {
+	return f.setRunning(containerName, false)
+}
+
+func (f *fakeDocker) setRunning(containerName string, running bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.containers[containerName]; !ok {
+		return fmt.Errorf("no such container: %s", containerName)
+	}
+	f.containers[containerName] = running
+	return nil
+}
+
+func (f *fakeDocker) RenameContainer(ctx context.Context, containerName, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	running, ok := f.containers[containerName]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerName)
+	}
+	if _, ok := f.containers[newName]; ok {
+		return fmt.Errorf("container %s already exists", newName)
+	}
+	delete(f.containers, containerName)
+	f.containers[newName] = running
+	return nil
+}
+
+func (f *fakeDocker) RemoveContainer(ctx context.Context, containerName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.containers, containerName)
+	return nil
+}
+
+func (f *fakeDocker) VolumeExists(ctx context.Context, volumeName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.volumes[volumeName]
+}
+
+func (f *fakeDocker) RemoveVolume(ctx context.Context, volumeName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.volumes, volumeName)
+	return nil
+}
+
+func (f *fakeDocker) CopyVolume(ctx context.Context, imageName, from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.volumes[from] {
+		return fmt.Errorf("no such volume: %s", from)
+	}
+	f.volumes[to] = true
+	f.copies = append(f.copies, from+" -> "+to)
+	return nil
+}
+
+func (f *fakeDocker) RemoveNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.networks, name)
+	return nil
+}
+
+func setupOperationTest(t *testing.T) (*TenantService, *fakeDocker) {
+	t.Helper()
+
+	baseDir := t.TempDir()
+	if err := database.InitDB(filepath.Join(baseDir, "test.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	settings := config.NewStore(&config.Config{
+		BaseDir: baseDir,
+		// Rolled back tenants are not waited on; nothing answers probes.
+		UpgradeHealthTimeout: time.Nanosecond,
+	})
+	docker := newFakeDocker()
+	s := NewTenantService(settings, nil, nil)
+	s.dockerClient = docker
+	return s, docker
+}
+
+type journalCase struct {
+	name string
+	op   database.Operation
+	// tenants are the rows present before recovery, by name and status.
+	tenants    map[string]string
+	containers map[string]bool
+	volumes    []string
+	// digest is the image the tenant record runs, for upgrades.
+	digest string
+
+	wantState      string
+	wantTenants    map[string]string
+	wantContainers map[string]bool
+	wantVolumes    []string
+	wantCopies     []string
+}
+
+func runJournalCases(t *testing.T, cases []journalCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, docker := setupOperationTest(t)
+
+			for i, name := range slices.Sorted(maps.Keys(tc.tenants)) {
+				dbTenant := &database.Tenant{
+					Name:          name,
+					Port:          9001 + i,
+					ContainerName: "files_" + name,
+					VolumeName:    name + "_settings_vol",
+					Status:        tc.tenants[name],
+					ImageDigest:   tc.digest,
+				}
+				if err := database.CreateTenant(dbTenant); err != nil {
+					t.Fatalf("CreateTenant: %v", err)
+				}
+			}
+			maps.Copy(docker.containers, tc.containers)
+			for _, volume := range tc.volumes {
+				docker.volumes[volume] = true
+			}
+
+			op := tc.op
+			op.TenantDir = filepath.Join(s.baseDir, "tenants", op.TenantName)
+			if err := os.MkdirAll(op.TenantDir, 0750); err != nil {
+				t.Fatal(err)
+			}
+			if err := database.CreateOperation(&op); err != nil {
+				t.Fatalf("CreateOperation: %v", err)
+			}
+
+			s.RecoverOperations(context.Background())
+
+			var recovered database.Operation
+			if err := database.DB.First(&recovered, op.ID).Error; err != nil {
+				t.Fatalf("reading operation: %v", err)
+			}
+			if recovered.State != tc.wantState {
+				t.Errorf("operation is %s (error %q), want %s", recovered.State, recovered.Error, tc.wantState)
+			}
+
+			tenants := make(map[string]string)
+			dbTenants, err := database.ListAllTenants()
+			if err != nil {
+				t.Fatalf("ListAllTenants: %v", err)
+			}
+			for _, dbTenant := range dbTenants {
+				tenants[dbTenant.Name] = dbTenant.Status
+			}
+			if !maps.Equal(tenants, orEmpty(tc.wantTenants)) {
+				t.Errorf("tenants = %v, want %v", tenants, tc.wantTenants)
+			}
+			if !maps.Equal(docker.containers, orEmpty(tc.wantContainers)) {
+				t.Errorf("containers = %v, want %v", docker.containers, tc.wantContainers)
+			}
+			if volumes := slices.Sorted(maps.Keys(docker.volumes)); !slices.Equal(volumes, tc.wantVolumes) {
+				t.Errorf("volumes = %v, want %v", volumes, tc.wantVolumes)
+			}
+			if !slices.Equal(docker.copies, tc.wantCopies) {
+				t.Errorf("volume copies = %v, want %v", docker.copies, tc.wantCopies)
+			}
+		})
+	}
+}
+
+func orEmpty[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return map[string]V{}
+	}
+	return m
+}
+
+func createOp(step string) database.Operation {
+	return database.Operation{
+		Kind:          OperationCreate,
+		TenantName:    "alpha",
+		Step:          step,
+		Port:          9001,
+		ContainerName: "files_alpha",
+		VolumeName:    "alpha_settings_vol",
+	}
+}
+
+func TestRecoverCreate(t *testing.T) {
+	var cases []journalCase
+	for _, step := range []string{stepStarted, stepDirectories, stepNetwork, stepContainer, stepVolume} {
+		cases = append(cases, journalCase{
+			name:       "rolled back after " + step,
+			op:         createOp(step),
+			containers: map[string]bool{"files_alpha": false},
+			volumes:    []string{"alpha_settings_vol"},
+			wantState:  database.OperationCompensated,
+		})
+	}
+
+	cases = append(cases, journalCase{
+		name:           "kept after " + stepRecord,
+		op:             createOp(stepRecord),
+		tenants:        map[string]string{"alpha": models.StatusRunning},
+		containers:     map[string]bool{"files_alpha": true},
+		volumes:        []string{"alpha_settings_vol"},
+		wantState:      database.OperationCompleted,
+		wantTenants:    map[string]string{"alpha": models.StatusRunning},
+		wantContainers: map[string]bool{"files_alpha": true},
+		wantVolumes:    []string{"alpha_settings_vol"},
+	})
+
+	clone := createOp(stepContainer)
+	clone.SourceName = "source"
+	clone.PreviousStatus = models.StatusRunning
+	cases = append(cases, journalCase{
+		name:           "rolled back clone restarts its source",
+		op:             clone,
+		tenants:        map[string]string{"source": models.StatusStopped},
+		containers:     map[string]bool{"files_alpha": false, "files_source": false},
+		volumes:        []string{"alpha_settings_vol", "source_settings_vol"},
+		wantState:      database.OperationCompensated,
+		wantTenants:    map[string]string{"source": models.StatusRunning},
+		wantContainers: map[string]bool{"files_source": true},
+		wantVolumes:    []string{"source_settings_vol"},
+	})
+
+	cloned := createOp(stepRecord)
+	cloned.SourceName = "source"
+	cloned.PreviousStatus = models.StatusRunning
+	cases = append(cases, journalCase{
+		name:           "kept clone restarts its source",
+		op:             cloned,
+		tenants:        map[string]string{"alpha": models.StatusRunning, "source": models.StatusStopped},
+		containers:     map[string]bool{"files_alpha": true, "files_source": false},
+		volumes:        []string{"alpha_settings_vol", "source_settings_vol"},
+		wantState:      database.OperationCompleted,
+		wantTenants:    map[string]string{"alpha": models.StatusRunning, "source": models.StatusRunning},
+		wantContainers: map[string]bool{"files_alpha": true, "files_source": true},
+		wantVolumes:    []string{"alpha_settings_vol", "source_settings_vol"},
+	})
+
+	runJournalCases(t, cases)
+}
+
+func TestRecoverDelete(t *testing.T) {
+	var cases []journalCase
+	for _, step := range []string{stepStarted, stepContainer, stepVolume, stepDirectories} {
+		op := createOp(step)
+		op.Kind = OperationDelete
+		tc := journalCase{
+			name:      "carried through after " + step,
+			op:        op,
+			tenants:   map[string]string{"alpha": models.StatusStopped},
+			wantState: database.OperationCompleted,
+		}
+		// Each step removed its resource before it was journaled.
+		if step == stepStarted {
+			tc.containers = map[string]bool{"files_alpha": false}
+		}
+		if step == stepStarted || step == stepContainer {
+			tc.volumes = []string{"alpha_settings_vol"}
+		}
+		cases = append(cases, tc)
+	}
+
+	runJournalCases(t, cases)
+}
+
+func upgradeOp(step string) database.Operation {
+	op := createOp(step)
+	op.Kind = OperationUpgrade
+	op.Image = "sha256:new"
+	op.PreviousImage = "sha256:old"
+	op.Snapshot = "alpha_settings_vol_snapshot"
+	return op
+}
+
+func TestRecoverUpgrade(t *testing.T) {
+	runJournalCases(t, []journalCase{
+		{
+			name:           "kept once recorded",
+			op:             upgradeOp(stepContainer),
+			tenants:        map[string]string{"alpha": models.StatusUpgrading},
+			digest:         "sha256:new",
+			containers:     map[string]bool{"files_alpha": true},
+			volumes:        []string{"alpha_settings_vol", "alpha_settings_vol_snapshot"},
+			wantState:      database.OperationCompleted,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+		},
+		{
+			name:           "rolled back after " + stepStarted,
+			op:             upgradeOp(stepStarted),
+			tenants:        map[string]string{"alpha": models.StatusUpgrading},
+			digest:         "sha256:old",
+			containers:     map[string]bool{"files_alpha": false},
+			volumes:        []string{"alpha_settings_vol"},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+		},
+		{
+			name:           "rolled back after " + stepSnapshot,
+			op:             upgradeOp(stepSnapshot),
+			tenants:        map[string]string{"alpha": models.StatusUpgrading},
+			digest:         "sha256:old",
+			containers:     map[string]bool{"files_alpha": false},
+			volumes:        []string{"alpha_settings_vol", "alpha_settings_vol_snapshot"},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+			wantCopies:     []string{"alpha_settings_vol_snapshot -> alpha_settings_vol"},
+		},
+		{
+			name:           "rolled back after " + stepContainer,
+			op:             upgradeOp(stepContainer),
+			tenants:        map[string]string{"alpha": models.StatusUpgrading},
+			digest:         "sha256:old",
+			volumes:        []string{"alpha_settings_vol", "alpha_settings_vol_snapshot"},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+			wantCopies:     []string{"alpha_settings_vol_snapshot -> alpha_settings_vol"},
+		},
+	})
+}
+
+func renameOp(step string) database.Operation {
+	op := createOp(step)
+	op.Kind = OperationRename
+	op.NewName = "beta"
+	op.PreviousStatus = models.StatusRunning
+	return op
+}
+
+func TestRecoverRename(t *testing.T) {
+	runJournalCases(t, []journalCase{
+		{
+			name:           "kept after " + stepRecord,
+			op:             renameOp(stepRecord),
+			tenants:        map[string]string{"beta": models.StatusRunning},
+			containers:     map[string]bool{"files_alpha": false, "files_beta": true},
+			volumes:        []string{"alpha_settings_vol", "beta_settings_vol"},
+			wantState:      database.OperationCompleted,
+			wantTenants:    map[string]string{"beta": models.StatusRunning},
+			wantContainers: map[string]bool{"files_beta": true},
+			wantVolumes:    []string{"beta_settings_vol"},
+		},
+		{
+			name:           "rolled back after " + stepStarted,
+			op:             renameOp(stepStarted),
+			tenants:        map[string]string{"alpha": models.StatusRenaming},
+			containers:     map[string]bool{"files_alpha": false},
+			volumes:        []string{"alpha_settings_vol"},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+		},
+		{
+			name:           "rolled back after " + stepContainer,
+			op:             renameOp(stepContainer),
+			tenants:        map[string]string{"alpha": models.StatusRenaming},
+			containers:     map[string]bool{"files_alpha": false, "files_beta": false},
+			volumes:        []string{"alpha_settings_vol", "beta_settings_vol"},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+			wantVolumes:    []string{"alpha_settings_vol"},
+		},
+	})
+}
+
+func recreateOp(step string) database.Operation {
+	op := createOp(step)
+	op.Kind = OperationRecreate
+	return op
+}
+
+func TestRecoverRecreate(t *testing.T) {
+	runJournalCases(t, []journalCase{
+		{
+			name:           "rolled back after " + stepStarted,
+			op:             recreateOp(stepStarted),
+			tenants:        map[string]string{"alpha": models.StatusRunning},
+			containers:     map[string]bool{"files_alpha": false},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+		},
+		{
+			name:           "rolled back before the replacement was created",
+			op:             recreateOp(stepContainer),
+			tenants:        map[string]string{"alpha": models.StatusRunning},
+			containers:     map[string]bool{"files_alpha_replaced": false},
+			wantState:      database.OperationCompensated,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+		},
+		{
+			name:           "kept once the replacement was created",
+			op:             recreateOp(stepContainer),
+			tenants:        map[string]string{"alpha": models.StatusRunning},
+			containers:     map[string]bool{"files_alpha": false, "files_alpha_replaced": false},
+			wantState:      database.OperationCompleted,
+			wantTenants:    map[string]string{"alpha": models.StatusRunning},
+			wantContainers: map[string]bool{"files_alpha": true},
+		},
+	})
+}
+
+func TestLockTenantsInAnyOrder(t *testing.T) {
+	s, _ := setupOperationTest(t)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				s.lockTenants("alpha", "beta")()
+			}()
+			go func() {
+				defer wg.Done()
+				s.lockTenants("beta", "alpha", "beta")()
+			}()
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lockTenants deadlocked")
+	}
+}
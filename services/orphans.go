@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"tenant-manager/config"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+const containerPrefix = "files_"
+
+// volumeSuffixes covers the settings volume and upgrade snapshot created by
+// the manager and the extra volumes created by the deploy scripts.
+var volumeSuffixes = []string{"_settings_vol", "_settings_snapshot", "_files_vol", "_config_vol"}
+
+// OrphanCollector finds containers, volumes, networks and tenant directories that no
+// tenant owns, and tenants whose container or directory has disappeared.
+// With the delete policy, resources that stay orphaned for the grace
+// period are removed; tenant rows are only ever removed on request.
+type OrphanCollector struct {
+	service  *TenantService
+	settings *config.Store
+
+	mu        sync.Mutex
+	firstSeen map[string]time.Time
+}
+
+func NewOrphanCollector(service *TenantService, settings *config.Store) *OrphanCollector {
+	return &OrphanCollector{
+		service:   service,
+		settings:  settings,
+		firstSeen: make(map[string]time.Time),
+	}
+}
+
+func (c *OrphanCollector) Run(ctx context.Context) {
+	for {
+		cfg := c.settings.Get()
+
+		orphans, err := c.Scan(ctx)
+		if err != nil {
+			log.Printf("Warning: orphan scan failed: %v", err)
+		} else if cfg.OrphanGCPolicy == "delete" {
+			c.collectExpired(ctx, orphans)
+		} else if len(orphans) > 0 {
+			log.Printf("Found %d orphaned resources, see /api/admin/orphans", len(orphans))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.OrphanGCInterval):
+		}
+	}
+}
+
+// Scan lists the current orphans. Docker and the filesystem are read before
+// the database so that a tenant being created concurrently is always
+// covered by its journal entry or its row.
+func (c *OrphanCollector) Scan(ctx context.Context) ([]models.Orphan, error) {
+	dc := c.service.dockerClient
+
+	containers, err := dc.ListContainerNames(ctx, containerPrefix)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := dc.ListVolumeNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := dc.ListNetworkNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := c.tenantDirectories()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := database.GetPendingOperations()
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := database.ListAllTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string]bool)
+	for _, op := range pending {
+		busy[op.TenantName] = true
+		if op.NewName != "" {
+			busy[op.NewName] = true
+		}
+		if op.SourceName != "" {
+			busy[op.SourceName] = true
+		}
+	}
+	owned := make(map[string]bool)
+	for _, tenant := range tenants {
+		owned[tenant.Name] = true
+	}
+	unowned := func(tenant string) bool {
+		return !owned[tenant] && !busy[tenant]
+	}
+
+	found := make([]models.Orphan, 0)
+	existingContainers := make(map[string]bool)
+	for _, name := range containers {
+		existingContainers[name] = true
+	}
+	for _, name := range containers {
+		tenant := strings.TrimPrefix(name, containerPrefix)
+		if !unowned(tenant) {
+			continue
+		}
+		// A container set aside by a recovery recreation belongs to the
+		// tenant it was replaced for. It is only left over once that
+		// tenant's own container exists again.
+		if base, ok := strings.CutSuffix(tenant, replacedContainerSuffix); ok && !unowned(base) {
+			if !busy[base] && existingContainers[containerPrefix+base] {
+				found = append(found, models.Orphan{Kind: models.OrphanContainer, Name: name, Tenant: base, Reason: "replaced container left behind"})
+			}
+			continue
+		}
+		found = append(found, models.Orphan{Kind: models.OrphanContainer, Name: name, Tenant: tenant, Reason: "no tenant record"})
+	}
+	for _, name := range volumes {
+		for _, suffix := range volumeSuffixes {
+			if !strings.HasSuffix(name, suffix) {
+				continue
+			}
+			if tenant := strings.TrimSuffix(name, suffix); unowned(tenant) {
+				found = append(found, models.Orphan{Kind: models.OrphanVolume, Name: name, Tenant: tenant, Reason: "no tenant record"})
+			}
+			break
+		}
+	}
+	existingNetworks := make(map[string]bool)
+	for _, name := range networks {
+		existingNetworks[name] = true
+		if !strings.HasPrefix(name, tenantNetworkPrefix) || !strings.HasSuffix(name, tenantNetworkSuffix) {
+			continue
+		}
+		tenant := strings.TrimSuffix(strings.TrimPrefix(name, tenantNetworkPrefix), tenantNetworkSuffix)
+		if unowned(tenant) {
+			found = append(found, models.Orphan{Kind: models.OrphanNetwork, Name: name, Tenant: tenant, Reason: "no tenant record"})
+		}
+	}
+	existingDirs := make(map[string]bool)
+	for _, tenant := range dirs {
+		existingDirs[tenant] = true
+		if unowned(tenant) {
+			found = append(found, models.Orphan{Kind: models.OrphanDirectory, Name: filepath.Join(c.service.baseDir, "tenants", tenant), Tenant: tenant, Reason: "no tenant record"})
+		}
+	}
+	for _, tenant := range tenants {
+		if busy[tenant.Name] {
+			continue
+		}
+
+		var missing []string
+		if !existingContainers[tenant.ContainerName] {
+			missing = append(missing, "container")
+		}
+		if tenant.Network != "" && !existingNetworks[tenant.Network] {
+			missing = append(missing, "network")
+		}
+		if !existingDirs[tenant.Name] {
+			missing = append(missing, "directory")
+		}
+		if len(missing) > 0 {
+			found = append(found, models.Orphan{Kind: models.OrphanTenant, Name: tenant.Name, Tenant: tenant.Name, Reason: strings.Join(missing, ", ") + " missing"})
+		}
+	}
+
+	return c.track(found), nil
+}
+
+// track stamps each orphan with the time it was first seen and forgets
+// resources that are no longer orphaned.
+func (c *OrphanCollector) track(found []models.Orphan) []models.Orphan {
+	cfg := c.settings.Get()
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]time.Time, len(found))
+	for i := range found {
+		orphan := &found[i]
+		orphan.ID = orphan.Kind + ":" + orphan.Name
+
+		firstSeen, ok := c.firstSeen[orphan.ID]
+		if !ok {
+			firstSeen = now
+		}
+		seen[orphan.ID] = firstSeen
+		orphan.FirstSeenAt = firstSeen
+
+		if cfg.OrphanGCPolicy == "delete" && orphan.Kind != models.OrphanTenant {
+			deletableAt := firstSeen.Add(cfg.OrphanGCGracePeriod)
+			orphan.DeletableAt = &deletableAt
+		}
+	}
+	c.firstSeen = seen
+
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found
+}
+
+func (c *OrphanCollector) tenantDirectories() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(c.service.baseDir, "tenants"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (c *OrphanCollector) collectExpired(ctx context.Context, orphans []models.Orphan) {
+	now := time.Now()
+	for _, orphan := range orphans {
+		if orphan.DeletableAt == nil || now.Before(*orphan.DeletableAt) {
+			continue
+		}
+		if err := c.remove(ctx, orphan); err != nil {
+			log.Printf("Warning: failed to remove orphaned %s %s: %v", orphan.Kind, orphan.Name, err)
+			continue
+		}
+		log.Printf("Removed orphaned %s %s", orphan.Kind, orphan.Name)
+	}
+}
+
+// Collect removes the requested orphans immediately, regardless of the
+// grace period. An empty ids list means every orphaned resource currently
+// reported; tenants with missing resources have to be listed by ID.
+func (c *OrphanCollector) Collect(ctx context.Context, ids []string) (*models.OrphanCollection, error) {
+	orphans, err := c.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.Orphan, len(orphans))
+	for _, orphan := range orphans {
+		byID[orphan.ID] = orphan
+	}
+	if len(ids) == 0 {
+		for _, orphan := range orphans {
+			if orphan.Kind != models.OrphanTenant {
+				ids = append(ids, orphan.ID)
+			}
+		}
+	}
+
+	result := &models.OrphanCollection{
+		Removed: make([]models.Orphan, 0),
+		Failed:  make([]models.OrphanFailure, 0),
+	}
+	for _, id := range ids {
+		orphan, ok := byID[id]
+		if !ok {
+			result.Failed = append(result.Failed, models.OrphanFailure{ID: id, Error: "not an orphan"})
+			continue
+		}
+		if err := c.remove(ctx, orphan); err != nil {
+			result.Failed = append(result.Failed, models.OrphanFailure{ID: id, Error: err.Error()})
+			continue
+		}
+		result.Removed = append(result.Removed, orphan)
+	}
+
+	return result, nil
+}
+
+func (c *OrphanCollector) remove(ctx context.Context, orphan models.Orphan) error {
+	dc := c.service.dockerClient
+
+	switch orphan.Kind {
+	case models.OrphanContainer:
+		return dc.RemoveContainer(ctx, orphan.Name)
+	case models.OrphanVolume:
+		return dc.RemoveVolume(ctx, orphan.Name)
+	case models.OrphanNetwork:
+		return dc.RemoveNetwork(ctx, orphan.Name)
+	case models.OrphanDirectory:
+		if err := os.RemoveAll(orphan.Name); err != nil {
+			return fmt.Errorf("failed to remove directory: %w", err)
+		}
+		return nil
+	case models.OrphanTenant:
+		return c.service.forgetTenant(orphan.Name)
+	}
+	return fmt.Errorf("unknown orphan kind %q", orphan.Kind)
+}
+
+// forgetTenant drops the record of a tenant whose resources are partly
+// gone. Its settings volume and files are left alone; from then on they
+// are reported as orphans of their own.
+func (s *TenantService) forgetTenant(name string) error {
+	unlock, err := s.lockIdleTenant(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := database.DeleteTenant(name); err != nil {
+		return fmt.Errorf("failed to delete tenant from database: %w", err)
+	}
+	s.invalidateDomainCache()
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		log.Printf("Warning: failed to update Prometheus targets: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+const (
+	ProxyModeHost = "host"
+	ProxyModePath = "path"
+	ProxyModeBoth = "both"
+
+	ProxyPathPrefix = "/t/"
+
+	proxyTouchInterval = time.Minute
+
+	// maxCachedDomains bounds the custom-domain cache, which also holds
+	// hosts that belong to no tenant.
+	maxCachedDomains = 10000
+)
+
+func tenantPathPrefix(name string) string {
+	return ProxyPathPrefix + name
+}
+
+// ResolveProxyTarget returns the in-network address of the tenant's
+// container, waking it first if it is hibernated.
+func (s *TenantService) ResolveProxyTarget(ctx context.Context, name string) (*url.URL, error) {
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	switch {
+	case dbTenant.Status == models.StatusHibernated:
+		if err := s.WakeTenant(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to wake tenant: %w", err)
+		}
+	case !isActiveStatus(dbTenant.Status):
+		return nil, fmt.Errorf("tenant is %s", dbTenant.Status)
+	}
+
+	port, err := s.internalPort(dbTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	s.touchFromProxy(name)
+
+	return &url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("%s:%d", dbTenant.ContainerName, port),
+	}, nil
+}
+
+// TenantFromHost extracts the tenant name from "<tenant>.<base-domain>"
+// or from a tenant's custom domain.
+func (s *TenantService) TenantFromHost(host string) (string, bool) {
+	if !s.cfg().ProxyEnabled || s.cfg().ProxyMode == ProxyModePath {
+		return "", false
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	host = strings.ToLower(host)
+	baseDomain := strings.ToLower(s.cfg().ProxyBaseDomain)
+	suffix := "." + baseDomain
+
+	// The API host is never a tenant, even when it is a subdomain of the
+	// base domain.
+	if host == baseDomain || s.isAPIHost(host) {
+		return "", false
+	}
+
+	// Tenant subdomains are never custom domains, so only other hosts are
+	// looked up.
+	if baseDomain == "" || !strings.HasSuffix(host, suffix) {
+		return s.tenantFromCustomDomain(host)
+	}
+
+	name := strings.TrimSuffix(host, suffix)
+	if name == "" || strings.Contains(name, ".") {
+		return "", false
+	}
+	return name, true
+}
+
+func (s *TenantService) isAPIHost(host string) bool {
+	for _, apiHost := range s.apiHosts() {
+		if strings.EqualFold(host, apiHost) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TenantService) apiHosts() []string {
+	cfg := s.cfg()
+	hosts := []string{cfg.PublicHost}
+	if public, err := url.Parse(cfg.ProxyPublicURL); err == nil {
+		hosts = append(hosts, public.Hostname())
+	}
+	return hosts
+}
+
+// checkTenantName rejects names whose subdomain is taken by the API, such
+// as "api" when the API is served from api.<base domain>.
+func (s *TenantService) checkTenantName(name string) error {
+	baseDomain := s.cfg().ProxyBaseDomain
+	if baseDomain == "" {
+		return nil
+	}
+
+	suffix := "." + strings.ToLower(baseDomain)
+	for _, apiHost := range s.apiHosts() {
+		apiHost = strings.ToLower(apiHost)
+		label, ok := strings.CutSuffix(apiHost, suffix)
+		if ok && label != "" && strings.EqualFold(name, label) {
+			return fmt.Errorf("tenant name %s is reserved for the API host %s", name, apiHost)
+		}
+	}
+	return nil
+}
+
+// tenantFromCustomDomain looks up a custom domain, caching the answer
+// until a tenant's name or domain changes. Unknown hosts are cached too,
+// as they are what most stray requests carry.
+func (s *TenantService) tenantFromCustomDomain(host string) (string, bool) {
+	s.domainMu.Lock()
+	name, ok := s.domainCache[host]
+	generation := s.domainGeneration
+	s.domainMu.Unlock()
+	if ok {
+		return name, name != ""
+	}
+
+	dbTenant, err := database.GetTenantByCustomDomain(host)
+	switch {
+	case err == nil:
+		name = dbTenant.Name
+	case err.Error() != "tenant not found":
+		return "", false
+	}
+
+	s.domainMu.Lock()
+	if generation == s.domainGeneration {
+		if len(s.domainCache) >= maxCachedDomains {
+			s.domainCache = make(map[string]string)
+		}
+		s.domainCache[host] = name
+	}
+	s.domainMu.Unlock()
+
+	return name, name != ""
+}
+
+// invalidateDomainCache drops cached custom-domain lookups. It is called
+// whenever a tenant is created, deleted, renamed or changed.
+func (s *TenantService) invalidateDomainCache() {
+	s.domainMu.Lock()
+	s.domainCache = make(map[string]string)
+	s.domainGeneration++
+	s.domainMu.Unlock()
+}
+
+// TenantFromPath extracts the tenant name from "/t/<tenant>/...".
+func (s *TenantService) TenantFromPath(path string) (string, bool) {
+	if !s.cfg().ProxyEnabled || s.cfg().ProxyMode == ProxyModeHost || !strings.HasPrefix(path, ProxyPathPrefix) {
+		return "", false
+	}
+
+	rest := strings.TrimPrefix(path, ProxyPathPrefix)
+	name, _, _ := strings.Cut(rest, "/")
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// UsesPathPrefix reports whether the tenant's container serves under
+// /t/<name>. Apps without a base URL setting always serve under /.
+func (s *TenantService) UsesPathPrefix(name string) bool {
+	if !s.cfg().ProxyEnabled || s.cfg().ProxyMode == ProxyModeHost {
+		return false
+	}
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return false
+	}
+	app, err := s.app(dbTenant.App)
+	return err == nil && app.BaseURLEnv != ""
+}
+
+func (s *TenantService) touchFromProxy(name string) {
+	s.proxyTouchMu.Lock()
+	now := time.Now()
+	if now.Sub(s.proxyTouched[name]) < proxyTouchInterval {
+		s.proxyTouchMu.Unlock()
+		return
+	}
+	s.proxyTouched[name] = now
+	s.proxyTouchMu.Unlock()
+
+	s.TouchTenant(name)
+}
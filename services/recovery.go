@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"tenant-manager/config"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+const OperationRecreate = "recreate"
+
+// replacedContainerSuffix marks a container set aside while its
+// replacement is created.
+const replacedContainerSuffix = "_replaced"
+
+// recoverableStatuses are the statuses the recovery monitor acts on.
+var recoverableStatuses = []string{models.StatusRunning, models.StatusUnhealthy, "restarting"}
+
+const (
+	RecoveryPolicyRestart    = "restart"
+	RecoveryPolicyRecreate   = "recreate"
+	RecoveryPolicyQuarantine = "quarantine"
+)
+
+type restartSample struct {
+	at           time.Time
+	restartCount int
+}
+
+// RecoveryMonitor watches Docker's restart counter and healthcheck status
+// and applies the configured recovery policy to crash-looping or hung
+// tenants. Tenants that keep failing after RecoveryMaxAttempts recoveries
+// within the window are quarantined.
+type RecoveryMonitor struct {
+	service          *TenantService
+	settings         *config.Store
+	policy           string
+	interval         time.Duration
+	window           time.Duration
+	restartThreshold int
+	maxAttempts      int
+
+	mu       sync.Mutex
+	samples  map[string][]restartSample
+	attempts map[string][]time.Time
+}
+
+func NewRecoveryMonitor(service *TenantService, settings *config.Store) *RecoveryMonitor {
+	m := &RecoveryMonitor{
+		service:  service,
+		settings: settings,
+		samples:  make(map[string][]restartSample),
+		attempts: make(map[string][]time.Time),
+	}
+	m.applySettings()
+	return m
+}
+
+func (m *RecoveryMonitor) applySettings() {
+	cfg := m.settings.Get()
+	m.policy = cfg.RecoveryPolicy
+	m.interval = cfg.RecoveryInterval
+	m.window = cfg.RecoveryWindow
+	m.restartThreshold = cfg.RecoveryRestartThreshold
+	m.maxAttempts = cfg.RecoveryMaxAttempts
+}
+
+func (m *RecoveryMonitor) Run(ctx context.Context) {
+	for {
+		m.applySettings()
+		m.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.interval):
+		}
+	}
+}
+
+func (m *RecoveryMonitor) CheckAll(ctx context.Context) {
+	tenants, err := database.GetTenantsByStatus(recoverableStatuses...)
+	if err != nil {
+		log.Printf("Warning: recovery monitor failed to list tenants: %v", err)
+		return
+	}
+
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		m.checkTenant(ctx, tenant)
+	}
+}
+
+func (m *RecoveryMonitor) checkTenant(ctx context.Context, tenant database.Tenant) {
+	state, err := m.service.dockerClient.InspectContainerState(ctx, tenant.ContainerName)
+	if err != nil {
+		return
+	}
+
+	restarts := m.recordSample(tenant.Name, state.RestartCount)
+
+	reason := ""
+	switch {
+	case restarts >= m.restartThreshold:
+		reason = fmt.Sprintf("container restarted %d times within %s", restarts, m.window)
+		m.service.recordEvent(models.EventTenantCrashLoop, tenant.Name, "Restart storm detected", map[string]interface{}{
+			"restarts":      restarts,
+			"window":        m.window.String(),
+			"restart_count": state.RestartCount,
+			"exit_code":     state.ExitCode,
+			"oom_killed":    state.OOMKilled,
+		})
+	case state.Health == "unhealthy":
+		reason = "docker healthcheck reports unhealthy"
+	default:
+		return
+	}
+
+	policy := m.policy
+	if m.recordAttempt(tenant.Name) > m.maxAttempts {
+		policy = RecoveryPolicyQuarantine
+		reason = fmt.Sprintf("%s; recovery attempts exhausted", reason)
+	}
+
+	m.resetSamples(tenant.Name)
+	m.service.recover(ctx, &tenant, policy, reason)
+}
+
+// recordSample stores the current restart counter and returns how many
+// restarts happened inside the window.
+func (m *RecoveryMonitor) recordSample(name string, restartCount int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-m.window)
+
+	samples := m.samples[name]
+	kept := samples[:0]
+	for _, sample := range samples {
+		if sample.at.After(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	kept = append(kept, restartSample{at: now, restartCount: restartCount})
+	m.samples[name] = kept
+
+	restarts := kept[len(kept)-1].restartCount - kept[0].restartCount
+	if restarts < 0 {
+		restarts = kept[len(kept)-1].restartCount
+	}
+	return restarts
+}
+
+func (m *RecoveryMonitor) resetSamples(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.samples, name)
+}
+
+func (m *RecoveryMonitor) recordAttempt(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-m.window)
+
+	kept := make([]time.Time, 0, len(m.attempts[name])+1)
+	for _, at := range m.attempts[name] {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	kept = append(kept, now)
+	m.attempts[name] = kept
+
+	return len(kept)
+}
+
+func (s *TenantService) recover(ctx context.Context, dbTenant *database.Tenant, policy, reason string) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return
+	}
+	defer done()
+
+	// Upgrades, renames and deletes stop and replace the container
+	// themselves; the monitor looks again on its next pass.
+	unlock, err := s.lockIdleTenant(dbTenant.Name)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	// The tenant was listed before the lock was taken; a stop or
+	// hibernation in between must not be undone.
+	dbTenant, err = database.GetTenantByName(dbTenant.Name)
+	if err != nil || !slices.Contains(recoverableStatuses, dbTenant.Status) {
+		return
+	}
+
+	status := models.StatusRunning
+
+	switch policy {
+	case RecoveryPolicyRecreate:
+		err = s.recreateContainer(ctx, dbTenant)
+	case RecoveryPolicyQuarantine:
+		status = models.StatusQuarantined
+		err = s.dockerClient.StopContainer(ctx, dbTenant.ContainerName)
+	default:
+		policy = RecoveryPolicyRestart
+		err = s.dockerClient.RestartContainer(ctx, dbTenant.ContainerName)
+	}
+
+	data := map[string]interface{}{
+		"policy": policy,
+		"reason": reason,
+	}
+
+	if err != nil {
+		data["error"] = err.Error()
+		database.UpdateTenantStatus(dbTenant.Name, models.StatusError)
+		s.recordEvent(models.EventTenantRecovery, dbTenant.Name, fmt.Sprintf("Recovery using %s policy failed", policy), data)
+		return
+	}
+
+	database.UpdateTenantStatus(dbTenant.Name, status)
+
+	if status == models.StatusQuarantined {
+		s.recordEvent(models.EventTenantQuarantined, dbTenant.Name, "Tenant quarantined", data)
+	} else {
+		s.recordEvent(models.EventTenantRecovery, dbTenant.Name, fmt.Sprintf("Recovered using %s policy", policy), data)
+	}
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		fmt.Printf("Warning: failed to update Prometheus targets: %v\n", err)
+	}
+}
+
+// recreateContainer replaces the tenant container with a fresh one built
+// from the same spec, keeping the settings volume and tenant directories.
+// The old container is only stopped and set aside under another name until
+// the new one has started, and is put back if it does not. The swap is
+// journaled so that a restart in between can finish or undo it.
+func (s *TenantService) recreateContainer(ctx context.Context, dbTenant *database.Tenant) error {
+	spec, err := s.containerSpec(dbTenant)
+	if err != nil {
+		return err
+	}
+
+	if err := s.prepareTenantDirs(dbTenant); err != nil {
+		return err
+	}
+
+	if err := s.setupTenantNetwork(ctx, dbTenant); err != nil {
+		return err
+	}
+
+	replaced := replacedContainerName(dbTenant.ContainerName)
+	if s.dockerClient.ContainerExists(ctx, replaced) {
+		if err := s.dockerClient.RemoveContainer(ctx, replaced); err != nil {
+			return fmt.Errorf("failed to remove leftover container: %w", err)
+		}
+	}
+
+	op := &database.Operation{
+		Kind:          OperationRecreate,
+		TenantName:    dbTenant.Name,
+		Step:          stepStarted,
+		Port:          dbTenant.Port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		Network:       dbTenant.Network,
+		PreviousImage: s.runningImage(dbTenant),
+	}
+	if err := database.CreateOperation(op); err != nil {
+		return fmt.Errorf("failed to journal container recreation: %w", err)
+	}
+
+	fail := func(err error) error {
+		if restoreErr := s.restoreReplacedContainer(ctx, op); restoreErr != nil {
+			database.RecordOperationError(op, restoreErr)
+			return fmt.Errorf("%v, and restoring the old container failed: %w", err, restoreErr)
+		}
+		database.FinishOperation(op, database.OperationCompensated, err)
+		return err
+	}
+
+	if err := s.dockerClient.StopContainer(ctx, dbTenant.ContainerName); err != nil {
+		return fail(err)
+	}
+	if err := s.dockerClient.RenameContainer(ctx, dbTenant.ContainerName, replaced); err != nil {
+		return fail(err)
+	}
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return fail(err)
+	}
+
+	if _, err := s.dockerClient.CreateAndStartContainer(ctx, spec); err != nil {
+		return fail(fmt.Errorf("failed to recreate container: %w", err))
+	}
+
+	s.finishRecreate(ctx, op)
+	return nil
+}
+
+func replacedContainerName(containerName string) string {
+	return containerName + replacedContainerSuffix
+}
+
+// finishRecreate drops the old container once its replacement runs.
+func (s *TenantService) finishRecreate(ctx context.Context, op *database.Operation) {
+	ctx = context.WithoutCancel(ctx)
+
+	replaced := replacedContainerName(op.ContainerName)
+	if s.dockerClient.ContainerExists(ctx, replaced) {
+		if err := s.dockerClient.RemoveContainer(ctx, replaced); err != nil {
+			log.Printf("Warning: failed to remove replaced container of %s: %v", op.TenantName, err)
+		}
+	}
+
+	if err := database.FinishOperation(op, database.OperationCompleted, nil); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// restoreReplacedContainer drops a failed replacement and puts the old
+// container back under its name and starts it.
+func (s *TenantService) restoreReplacedContainer(ctx context.Context, op *database.Operation) error {
+	ctx = context.WithoutCancel(ctx)
+
+	replaced := replacedContainerName(op.ContainerName)
+	if s.dockerClient.ContainerExists(ctx, replaced) {
+		if s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+			if err := s.dockerClient.RemoveContainer(ctx, op.ContainerName); err != nil {
+				return err
+			}
+		}
+		if err := s.dockerClient.RenameContainer(ctx, replaced, op.ContainerName); err != nil {
+			return err
+		}
+	}
+	return s.dockerClient.StartContainer(ctx, op.ContainerName)
+}
+
+// recoverRecreate finishes a recreation interrupted by a restart. A
+// replacement that was already created is kept; otherwise the old
+// container is put back.
+func (s *TenantService) recoverRecreate(ctx context.Context, op *database.Operation) error {
+	replaced := replacedContainerName(op.ContainerName)
+	if s.dockerClient.ContainerExists(ctx, replaced) && s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+		if err := s.dockerClient.StartContainer(ctx, op.ContainerName); err != nil {
+			database.RecordOperationError(op, err)
+			return err
+		}
+		s.finishRecreate(ctx, op)
+		return nil
+	}
+
+	if err := s.restoreReplacedContainer(ctx, op); err != nil {
+		database.RecordOperationError(op, err)
+		return err
+	}
+	return database.FinishOperation(op, database.OperationCompensated, fmt.Errorf("interrupted after step %s", op.Step))
+}
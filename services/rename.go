@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"tenant-manager/database"
+	"tenant-manager/models"
+)
+
+const OperationRename = "rename"
+
+// RenameTenant moves a tenant to a new name. The settings volume is copied
+// and the directory moved while the container is stopped, and a container
+// under the new name is created from the same spec. The old container and
+// volume are only removed once the database points at the new ones; until
+// then every step is undone on failure.
+func (s *TenantService) RenameTenant(ctx context.Context, name string, req models.RenameTenantRequest) (*models.Tenant, error) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	newName := req.Name
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	if newName == name {
+		return nil, fmt.Errorf("tenant is already named %s", name)
+	}
+	if err := s.checkTenantName(newName); err != nil {
+		return nil, err
+	}
+	if _, err := database.GetTenantByName(newName); err == nil {
+		return nil, fmt.Errorf("tenant %s already exists", newName)
+	}
+
+	running := isActiveStatus(dbTenant.Status)
+	if !running && dbTenant.Status != models.StatusStopped {
+		return nil, fmt.Errorf("tenant must be running or stopped to rename, it is %s", dbTenant.Status)
+	}
+
+	op := &database.Operation{
+		Kind:          OperationRename,
+		TenantName:    name,
+		NewName:       newName,
+		Step:          stepStarted,
+		Port:          dbTenant.Port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		TenantDir:     filepath.Join(s.baseDir, "tenants", name),
+		Network:       dbTenant.Network,
+		PreviousImage: s.runningImage(dbTenant),
+
+		PreviousStatus: dbTenant.Status,
+	}
+	// The status and the new name are checked again under the lock, as a
+	// concurrent request may have changed either.
+	check := func() error {
+		current, err := database.GetTenantByName(name)
+		if err != nil {
+			return fmt.Errorf("tenant not found: %w", err)
+		}
+		if current.Status != dbTenant.Status {
+			return fmt.Errorf("tenant changed to %s while preparing the rename", current.Status)
+		}
+		if _, err := database.GetTenantByName(newName); err == nil {
+			return fmt.Errorf("tenant %s already exists", newName)
+		}
+		return nil
+	}
+	if err := s.reserveOperation(op, check, name, newName); err != nil {
+		return nil, fmt.Errorf("failed to journal tenant rename: %w", err)
+	}
+
+	if err := database.UpdateTenantStatus(name, models.StatusRenaming); err != nil {
+		database.FinishOperation(op, database.OperationCompensated, err)
+		return nil, fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	renamed := *dbTenant
+	renamed.Name = newName
+	renamed.ContainerName = fmt.Sprintf("files_%s", newName)
+	renamed.VolumeName = fmt.Sprintf("%s_settings_vol", newName)
+	if dbTenant.Network != "" {
+		renamed.Network = tenantNetworkName(newName)
+	}
+
+	if err := s.runRename(ctx, op, &renamed, running); err != nil {
+		if rollbackErr := s.rollbackRename(ctx, op, err); rollbackErr != nil {
+			return nil, fmt.Errorf("rename failed (%v) and rollback failed: %w", err, rollbackErr)
+		}
+		return nil, fmt.Errorf("rename failed and was rolled back: %w", err)
+	}
+
+	s.finishRename(ctx, op)
+
+	return s.GetTenant(ctx, newName)
+}
+
+func (s *TenantService) runRename(ctx context.Context, op *database.Operation, renamed *database.Tenant, running bool) error {
+	if running {
+		if err := s.dockerClient.StopContainer(ctx, op.ContainerName); err != nil {
+			return fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+
+	if err := s.dockerClient.CopyVolume(ctx, op.PreviousImage, op.VolumeName, renamed.VolumeName); err != nil {
+		return fmt.Errorf("failed to copy settings volume: %w", err)
+	}
+	if err := database.UpdateOperationStep(op, stepVolume); err != nil {
+		return err
+	}
+
+	newDir := filepath.Join(s.baseDir, "tenants", renamed.Name)
+	if err := os.Rename(op.TenantDir, newDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to move tenant directory: %w", err)
+	}
+	if err := database.UpdateOperationStep(op, stepDirectories); err != nil {
+		return err
+	}
+
+	// An isolated tenant moves to a network of its own name, so the old
+	// name is not left pointing at it.
+	if err := s.setupTenantNetwork(ctx, renamed); err != nil {
+		return err
+	}
+	if err := database.UpdateOperationStep(op, stepNetwork); err != nil {
+		return err
+	}
+
+	spec, err := s.containerSpec(renamed)
+	if err != nil {
+		return err
+	}
+	if _, err := s.dockerClient.CreateContainer(ctx, spec); err != nil {
+		return fmt.Errorf("failed to create renamed container: %w", err)
+	}
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return err
+	}
+
+	if running {
+		if err := s.dockerClient.StartContainer(ctx, renamed.ContainerName); err != nil {
+			return fmt.Errorf("failed to start renamed container: %w", err)
+		}
+		if err := s.waitUntilReady(ctx, renamed, s.cfg().UpgradeHealthTimeout); err != nil {
+			return fmt.Errorf("renamed tenant is not healthy: %w", err)
+		}
+	}
+
+	fields := map[string]interface{}{
+		"container_name": renamed.ContainerName,
+		"volume_name":    renamed.VolumeName,
+		"network":        renamed.Network,
+		"status":         op.PreviousStatus,
+	}
+	if err := database.RenameTenant(op.TenantName, op.NewName, fields); err != nil {
+		return err
+	}
+	return database.UpdateOperationStep(op, stepRecord)
+}
+
+// rollbackRename removes whatever was created under the new name and
+// restarts the old container. The old volume is never written to, so it
+// needs no restoring.
+func (s *TenantService) rollbackRename(ctx context.Context, op *database.Operation, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	fail := func(err error) error {
+		database.RecordOperationError(op, err)
+		return err
+	}
+
+	newContainer := fmt.Sprintf("files_%s", op.NewName)
+	if s.dockerClient.ContainerExists(ctx, newContainer) {
+		if err := s.dockerClient.RemoveContainer(ctx, newContainer); err != nil {
+			return fail(err)
+		}
+	}
+
+	if op.Network != "" {
+		if err := s.dockerClient.RemoveNetwork(ctx, tenantNetworkName(op.NewName)); err != nil {
+			return fail(err)
+		}
+	}
+
+	newVolume := fmt.Sprintf("%s_settings_vol", op.NewName)
+	if s.dockerClient.VolumeExists(ctx, newVolume) {
+		if err := s.dockerClient.RemoveVolume(ctx, newVolume); err != nil {
+			return fail(err)
+		}
+	}
+
+	newDir := filepath.Join(s.baseDir, "tenants", op.NewName)
+	if _, err := os.Stat(op.TenantDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(newDir, op.TenantDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fail(fmt.Errorf("failed to move tenant directory back: %w", err))
+		}
+	}
+
+	if isActiveStatus(op.PreviousStatus) {
+		if err := s.dockerClient.StartContainer(ctx, op.ContainerName); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := database.UpdateTenantStatus(op.TenantName, op.PreviousStatus); err != nil {
+		return fail(err)
+	}
+	return database.FinishOperation(op, database.OperationCompensated, cause)
+}
+
+// finishRename drops the resources left under the old name once the
+// database records the new one.
+func (s *TenantService) finishRename(ctx context.Context, op *database.Operation) {
+	ctx = context.WithoutCancel(ctx)
+	s.invalidateDomainCache()
+
+	if s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+		if err := s.dockerClient.RemoveContainer(ctx, op.ContainerName); err != nil {
+			log.Printf("Warning: failed to remove old container of %s: %v", op.NewName, err)
+		}
+	}
+	if s.dockerClient.VolumeExists(ctx, op.VolumeName) {
+		if err := s.dockerClient.RemoveVolume(ctx, op.VolumeName); err != nil {
+			log.Printf("Warning: failed to remove old settings volume of %s: %v", op.NewName, err)
+		}
+	}
+	if op.Network != "" {
+		if err := s.dockerClient.RemoveNetwork(ctx, op.Network); err != nil {
+			log.Printf("Warning: failed to remove old network of %s: %v", op.NewName, err)
+		}
+	}
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		log.Printf("Warning: failed to update Prometheus targets: %v", err)
+	}
+
+	if err := database.FinishOperation(op, database.OperationCompleted, nil); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	s.recordEvent(models.EventTenantRenamed, op.NewName, "Tenant renamed", map[string]interface{}{
+		"previous_name": op.TenantName,
+	})
+}
+
+// recoverRename completes a rename that reached the database and rolls
+// back any other.
+func (s *TenantService) recoverRename(ctx context.Context, op *database.Operation) error {
+	if _, err := database.GetTenantByName(op.NewName); err == nil {
+		s.finishRename(ctx, op)
+		return nil
+	}
+
+	if _, err := database.GetTenantByName(op.TenantName); err != nil {
+		return database.FinishOperation(op, database.OperationCompensated, err)
+	}
+	return s.rollbackRename(ctx, op, fmt.Errorf("interrupted after step %s", op.Step))
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"tenant-manager/utils"
+)
+
+// applySecurityProfile records the configured hardening on a new tenant.
+// The tenant keeps this profile when its container is recreated later.
+func (s *TenantService) applySecurityProfile(dbTenant *database.Tenant) {
+	cfg := s.cfg()
+
+	if cfg.TenantUIDBase > 0 {
+		dbTenant.RunAsUID = cfg.TenantUIDBase + dbTenant.Port
+		dbTenant.RunAsGID = dbTenant.RunAsUID
+	}
+	dbTenant.CapDrop = strings.Join(cfg.TenantCapDrop, ",")
+	dbTenant.NoNewPrivileges = cfg.TenantNoNewPrivileges
+	dbTenant.ReadOnlyRootfs = cfg.TenantReadOnlyRootfs
+	dbTenant.SeccompProfile = cfg.TenantSeccompProfile
+	dbTenant.AppArmorProfile = cfg.TenantAppArmorProfile
+}
+
+func (s *TenantService) containerSecurity(dbTenant *database.Tenant) utils.ContainerSecurity {
+	sec := utils.ContainerSecurity{
+		UID:             dbTenant.RunAsUID,
+		GID:             dbTenant.RunAsGID,
+		CapDrop:         splitList(dbTenant.CapDrop),
+		NoNewPrivileges: dbTenant.NoNewPrivileges,
+		ReadOnlyRootfs:  dbTenant.ReadOnlyRootfs,
+		SeccompProfile:  dbTenant.SeccompProfile,
+		AppArmorProfile: dbTenant.AppArmorProfile,
+	}
+	if sec.ReadOnlyRootfs {
+		sec.Tmpfs = s.cfg().TenantTmpfs
+	}
+	return sec
+}
+
+// prepareTenantDirs creates the files and config directories. Hardened
+// tenants get them owned by their own user; root tenants keep the old
+// world-writable directories.
+func (s *TenantService) prepareTenantDirs(dbTenant *database.Tenant) error {
+	tenantDir := filepath.Join(s.baseDir, "tenants", dbTenant.Name)
+
+	for _, dir := range []string{"files", "config"} {
+		path := filepath.Join(tenantDir, dir)
+		if err := os.MkdirAll(path, 0750); err != nil {
+			return fmt.Errorf("failed to create %s directory: %w", dir, err)
+		}
+
+		if dbTenant.RunAsUID == 0 {
+			os.Chmod(path, 0777)
+			continue
+		}
+
+		if err := os.Chown(path, dbTenant.RunAsUID, dbTenant.RunAsGID); err != nil {
+			log.Printf("Warning: failed to hand %s to uid %d, falling back to mode 0777: %v", path, dbTenant.RunAsUID, err)
+			os.Chmod(path, 0777)
+		}
+	}
+
+	return nil
+}
+
+func securityProfile(dbTenant *database.Tenant) *models.SecurityProfile {
+	return &models.SecurityProfile{
+		UID:             dbTenant.RunAsUID,
+		GID:             dbTenant.RunAsGID,
+		CapDrop:         splitList(dbTenant.CapDrop),
+		NoNewPrivileges: dbTenant.NoNewPrivileges,
+		ReadOnlyRootfs:  dbTenant.ReadOnlyRootfs,
+		SeccompProfile:  dbTenant.SeccompProfile,
+		AppArmorProfile: dbTenant.AppArmorProfile,
+	}
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
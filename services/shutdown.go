@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDraining is returned for operations started after shutdown began.
+var ErrDraining = errors.New("tenant manager is shutting down")
+
+// rollbackGrace is how long Drain waits for cancelled operations to run
+// their compensation steps.
+const rollbackGrace = 30 * time.Second
+
+// beginOperation registers a multi-step operation so that shutdown can
+// wait for it. The returned context is cancelled when the drain period
+// runs out; callers must then roll back using a context that outlives it.
+func (s *TenantService) beginOperation(ctx context.Context) (context.Context, func(), error) {
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+
+	if s.draining {
+		return nil, nil, ErrDraining
+	}
+	s.ops.Add(1)
+
+	opCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.opsCtx, cancel)
+	return opCtx, func() {
+		stop()
+		cancel()
+		s.ops.Done()
+	}, nil
+}
+
+// Draining reports whether shutdown has started.
+func (s *TenantService) Draining() bool {
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+	return s.draining
+}
+
+// Drain rejects new operations and waits for the running ones. When ctx
+// expires first, the remaining operations are cancelled and given a short
+// grace period to roll back.
+func (s *TenantService) Drain(ctx context.Context) error {
+	s.opsMu.Lock()
+	s.draining = true
+	s.opsMu.Unlock()
+
+	s.closeWakeListeners()
+
+	done := make(chan struct{})
+	go func() {
+		s.ops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.cancelOps()
+	select {
+	case <-done:
+		return fmt.Errorf("in-flight operations did not finish in time and were rolled back")
+	case <-time.After(rollbackGrace):
+		return fmt.Errorf("in-flight operations did not finish rolling back")
+	}
+}
+
+// closeWakeListeners releases the ports of hibernated tenants. The
+// tenants stay hibernated and are re-armed on the next start.
+func (s *TenantService) closeWakeListeners() {
+	s.wakeMu.Lock()
+	defer s.wakeMu.Unlock()
+
+	for name, listener := range s.wakeListeners {
+		listener.Close()
+		delete(s.wakeListeners, name)
+	}
+}
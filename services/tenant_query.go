@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+// tenantCursor returns the position after the given tenant in a listing
+// sorted by the given field.
+func tenantCursor(tenant *database.Tenant, sort, order string) models.TenantCursor {
+	cursor := models.TenantCursor{Sort: sort, Order: order, ID: tenant.ID}
+
+	switch sort {
+	case models.SortName:
+		cursor.Value = tenant.Name
+	case models.SortPort:
+		cursor.Value = strconv.Itoa(tenant.Port)
+	case models.SortStatus:
+		cursor.Value = tenant.Status
+	case models.SortCreatedAt:
+		cursor.Value = tenant.CreatedAt.Format(time.RFC3339Nano)
+	case models.SortUsage:
+		used := tenant.CreatedAt
+		if tenant.LastActivityAt != nil {
+			used = *tenant.LastActivityAt
+		}
+		cursor.Value = used.Format(time.RFC3339Nano)
+	}
+	return cursor
+}
+
+// cursorKey converts the value of a cursor back to the type of its sort
+// column.
+func cursorKey(cursor *models.TenantCursor) (*database.TenantKey, error) {
+	key := &database.TenantKey{ID: cursor.ID}
+
+	switch cursor.Sort {
+	case models.SortPort:
+		port, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cursor")
+		}
+		key.Value = port
+	case models.SortCreatedAt, models.SortUsage:
+		at, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cursor")
+		}
+		key.Value = at
+	default:
+		key.Value = cursor.Value
+	}
+	return key, nil
+}
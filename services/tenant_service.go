@@ -5,51 +5,157 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"sync"
+	"tenant-manager/config"
 	"tenant-manager/database"
 	"tenant-manager/models"
 	"tenant-manager/utils"
+	"time"
 )
 
 type TenantService struct {
-	dockerClient *utils.DockerClient
+	settings     *config.Store
+	dockerClient dockerAPI
+	webhooks     *WebhookService
 	baseDir      string
+
+	wakeMu        sync.Mutex
+	wakeListeners map[string]net.Listener
+	wakeInFlight  map[string]chan struct{}
+
+	proxyTouchMu sync.Mutex
+	proxyTouched map[string]time.Time
+
+	domainMu         sync.Mutex
+	domainCache      map[string]string
+	domainGeneration uint64
+
+	opsMu     sync.Mutex
+	ops       sync.WaitGroup
+	draining  bool
+	opsCtx    context.Context
+	cancelOps context.CancelFunc
+
+	// apps caches catalog templates by name; templates never change once
+	// created.
+	apps sync.Map
+
+	// tenantLocks holds a *sync.Mutex per tenant name; see lockTenants.
+	tenantLocks sync.Map
 }
 
-func NewTenantService(dockerClient *utils.DockerClient, baseDir string) *TenantService {
+func NewTenantService(settings *config.Store, dockerClient *utils.DockerClient, webhooks *WebhookService) *TenantService {
+	opsCtx, cancelOps := context.WithCancel(context.Background())
 	return &TenantService{
-		dockerClient: dockerClient,
-		baseDir:      baseDir,
+		settings:      settings,
+		dockerClient:  dockerClient,
+		webhooks:      webhooks,
+		baseDir:       settings.Get().BaseDir,
+		wakeListeners: make(map[string]net.Listener),
+		wakeInFlight:  make(map[string]chan struct{}),
+		proxyTouched:  make(map[string]time.Time),
+		domainCache:   make(map[string]string),
+		opsCtx:        opsCtx,
+		cancelOps:     cancelOps,
 	}
 }
 
+func (s *TenantService) cfg() *config.Config {
+	return s.settings.Get()
+}
+
+func (s *TenantService) publish(eventType, name string, data interface{}) {
+	if s.webhooks == nil {
+		return
+	}
+	s.webhooks.Publish(eventType, name, data)
+}
+
+// syncStatus reconciles the stored status with Docker and reports a
+// tenant.down event when a running tenant is found not running.
+func (s *TenantService) syncStatus(ctx context.Context, dbTenant *database.Tenant) string {
+	status := dbTenant.Status
+	if status == models.StatusUpgrading || status == models.StatusRenaming || !s.dockerClient.ContainerExists(ctx, dbTenant.ContainerName) {
+		return status
+	}
+
+	containerStatus, err := s.dockerClient.InspectContainer(ctx, dbTenant.ContainerName)
+	if err != nil {
+		return status
+	}
+
+	if containerStatus == models.StatusRunning && dbTenant.Status == models.StatusUnhealthy {
+		return dbTenant.Status
+	}
+
+	if containerStatus != models.StatusRunning && isParkedStatus(dbTenant.Status) {
+		return dbTenant.Status
+	}
+
+	if containerStatus != dbTenant.Status {
+		database.UpdateTenantStatus(dbTenant.Name, containerStatus)
+		if isActiveStatus(dbTenant.Status) {
+			s.recordEvent(models.EventTenantDown, dbTenant.Name, "Container is no longer running", map[string]interface{}{
+				"previous_status": dbTenant.Status,
+				"status":          containerStatus,
+			})
+		}
+	}
+
+	return containerStatus
+}
+
+// isParkedStatus reports whether the manager deliberately stopped the
+// container, so Docker's "stopped" must not overwrite the status.
+func isParkedStatus(status string) bool {
+	return status == models.StatusQuarantined || status == models.StatusHibernated
+}
+
+// isActiveStatus reports whether the tenant's container is expected to be up.
+func isActiveStatus(status string) bool {
+	return status == models.StatusRunning || status == models.StatusUnhealthy
+}
+
 type PrometheusTargets struct {
 	Targets []string          `json:"targets"`
 	Labels  map[string]string `json:"labels"`
 }
 
 func (s *TenantService) UpdatePrometheusTargets() error {
-	dbTenants, _, err := database.GetAllTenants(1, 1000)
+	dbTenants, err := database.ListAllTenants()
 	if err != nil {
 		return fmt.Errorf("failed to get tenants: %w", err)
 	}
 
-	targets := make([]string, 0)
-	for _, dbTenant := range dbTenants {
-		if dbTenant.Status == models.StatusRunning {
-			target := fmt.Sprintf("http://%s:80/", dbTenant.ContainerName)
-			targets = append(targets, target)
+	promTargets := make([]PrometheusTargets, 0, len(dbTenants))
+	for i := range dbTenants {
+		dbTenant := &dbTenants[i]
+		if !isActiveStatus(dbTenant.Status) {
+			continue
+		}
+
+		app, err := s.app(dbTenant.App)
+		if err != nil {
+			return err
+		}
+
+		publicURL := s.tenantURL(dbTenant)
+		target := fmt.Sprintf("http://%s:%d/", dbTenant.ContainerName, app.InternalPort)
+		if s.cfg().PrometheusTargetMode == "public" {
+			target = publicURL
 		}
-	}
 
-	promTargets := []PrometheusTargets{
-		{
-			Targets: targets,
+		promTargets = append(promTargets, PrometheusTargets{
+			Targets: []string{target},
 			Labels: map[string]string{
-				"service": "filebrowser",
+				"service":    app.Name,
+				"tenant":     dbTenant.Name,
+				"public_url": publicURL,
 			},
-		},
+		})
 	}
 
 	monitoringDir := filepath.Join(s.baseDir, "monitoring")
@@ -70,122 +176,214 @@ func (s *TenantService) UpdatePrometheusTargets() error {
 	return nil
 }
 
-func (s *TenantService) CreateTenant(ctx context.Context, name string) (*models.Tenant, error) {
-	_, err := database.GetTenantByName(name)
+// tenantImage is the exact image a tenant runs. Tenants created before
+// images were pinned follow the configured docker_image.
+func tenantImage(dbTenant *database.Tenant) string {
+	if dbTenant.ImageDigest != "" {
+		return dbTenant.ImageDigest
+	}
+	return dbTenant.Image
+}
+
+func (s *TenantService) CreateTenant(ctx context.Context, req models.CreateTenantRequest) (*models.Tenant, error) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	name := req.Name
+	if err := s.checkTenantName(name); err != nil {
+		return nil, err
+	}
+	_, err = database.GetTenantByName(name)
 	if err == nil {
 		return nil, fmt.Errorf("tenant already exists")
 	}
 
-	port, err := database.GetNextAvailablePort()
+	if req.CustomDomain != "" {
+		if existing, err := database.GetTenantByCustomDomain(req.CustomDomain); err == nil {
+			return nil, fmt.Errorf("domain is already assigned to tenant %s", existing.Name)
+		}
+	}
+
+	port, err := database.GetNextAvailablePort(s.cfg().PortBase)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get next available port: %w", err)
 	}
 
 	tenantDir := filepath.Join(s.baseDir, "tenants", name)
-	filesPath := filepath.Join(tenantDir, "files")
-	configPath := filepath.Join(tenantDir, "config")
 
-	if err := os.MkdirAll(filesPath, 0777); err != nil {
-		return nil, fmt.Errorf("failed to create files directory: %w", err)
+	now := time.Now()
+	dbTenant := &database.Tenant{
+		Name:               name,
+		Port:               port,
+		ContainerName:      fmt.Sprintf("files_%s", name),
+		VolumeName:         fmt.Sprintf("%s_settings_vol", name),
+		Status:             models.StatusRunning,
+		IdleTimeoutMinutes: req.IdleTimeoutMinutes,
+		LastActivityAt:     &now,
+		Egress:             "allow",
+		Description:        req.Description,
+		RestartPolicy:      models.RestartUnlessStopped,
+	}
+	if req.CustomDomain != "" {
+		dbTenant.CustomDomain = &req.CustomDomain
+	}
+	if s.cfg().NetworkIsolation {
+		dbTenant.Network = tenantNetworkName(name)
+		dbTenant.Egress = s.cfg().TenantEgress
+	}
+	s.applySecurityProfile(dbTenant)
+
+	app, err := s.app(req.App)
+	if err != nil {
+		return nil, err
 	}
+	dbTenant.App = app.Name
+	dbTenant.Image = req.Image
+	if dbTenant.Image == "" {
+		dbTenant.Image = s.appImage(app)
+	}
+	containerName := dbTenant.ContainerName
 
-	if err := os.MkdirAll(configPath, 0777); err != nil {
-		os.RemoveAll(tenantDir)
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	op, err := s.startOperation(OperationCreate, dbTenant, tenantDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to journal tenant creation: %w", err)
 	}
 
-	os.Chmod(filesPath, 0777)
-	os.Chmod(configPath, 0777)
+	fail := func(err error) (*models.Tenant, error) {
+		if rollbackErr := s.compensateCreate(ctx, op, err); rollbackErr != nil {
+			fmt.Printf("Warning: %v\n", rollbackErr)
+		}
+		return nil, err
+	}
+
+	digest, err := s.dockerClient.ResolveImage(ctx, dbTenant.Image)
+	if err != nil {
+		return fail(err)
+	}
+	dbTenant.ImageDigest = digest
+
+	if err := s.prepareTenantDirs(dbTenant); err != nil {
+		return fail(err)
+	}
+
+	if err := database.UpdateOperationStep(op, stepDirectories); err != nil {
+		return fail(err)
+	}
+
+	if err := s.setupTenantNetwork(ctx, dbTenant); err != nil {
+		return fail(err)
+	}
 
-	containerName := fmt.Sprintf("files_%s", name)
-	volumeName := fmt.Sprintf("%s_settings_vol", name)
+	if err := database.UpdateOperationStep(op, stepNetwork); err != nil {
+		return fail(err)
+	}
 
-	_, err = s.dockerClient.CreateAndStartContainer(ctx, name, port, filesPath, configPath)
+	spec, err := s.containerSpec(dbTenant)
 	if err != nil {
-		os.RemoveAll(tenantDir)
-		return nil, fmt.Errorf("failed to create container: %w", err)
+		return fail(err)
 	}
 
-	password, err := s.dockerClient.GetContainerLogs(ctx, containerName)
+	_, err = s.dockerClient.CreateAndStartContainer(ctx, spec)
 	if err != nil {
-		password = ""
+		return fail(fmt.Errorf("failed to create container: %w", err))
 	}
 
-	dbTenant := &database.Tenant{
-		Name:          name,
-		Port:          port,
-		ContainerName: containerName,
-		VolumeName:    volumeName,
-		Status:        models.StatusRunning,
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return fail(err)
+	}
+
+	username, password := s.credentials(ctx, dbTenant)
+
+	if err := database.CreateTenantWithLabels(dbTenant, req.Labels); err != nil {
+		return fail(fmt.Errorf("failed to save tenant to database: %w", err))
 	}
+	s.invalidateDomainCache()
 
-	if err := database.CreateTenant(dbTenant); err != nil {
-		s.dockerClient.RemoveContainer(ctx, containerName)
-		s.dockerClient.RemoveVolume(ctx, volumeName)
-		os.RemoveAll(tenantDir)
-		return nil, fmt.Errorf("failed to save tenant to database: %w", err)
+	if err := database.FinishOperation(op, database.OperationCompleted, nil); err != nil {
+		fmt.Printf("Warning: %v\n", err)
 	}
 
 	if err := s.UpdatePrometheusTargets(); err != nil {
 		fmt.Printf("Warning: failed to update Prometheus targets: %v\n", err)
 	}
 
-	tenant := &models.Tenant{
-		ID:            int(dbTenant.ID),
-		Name:          name,
-		Port:          port,
-		ContainerName: containerName,
-		VolumeName:    volumeName,
-		Status:        models.StatusRunning,
-		URL:           fmt.Sprintf("http://localhost:%d", port),
-		Username:      "admin",
-		Password:      password,
-		CreatedAt:     dbTenant.CreatedAt,
-		UpdatedAt:     dbTenant.UpdatedAt,
-	}
+	s.recordEvent(models.EventTenantCreated, name, "Tenant created", map[string]interface{}{
+		"port":           port,
+		"container_name": containerName,
+	})
+
+	tenant := s.toTenant(dbTenant, models.StatusRunning, username, password)
+	tenant.Labels = req.Labels
 
 	return tenant, nil
 }
 
-func (s *TenantService) ListTenants(ctx context.Context, page, perPage int) ([]models.Tenant, models.PaginationMeta, error) {
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 10
+func (s *TenantService) ListTenants(ctx context.Context, query models.ListTenantsQuery) ([]models.Tenant, models.PaginationMeta, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, models.PaginationMeta{}, fmt.Errorf("invalid query: %w", err)
+	}
+	page, perPage := query.Page, query.PerPage
+
+	filter := database.TenantFilter{
+		Labels:        labelRequirements(query.Labels),
+		Query:         query.Query,
+		Statuses:      query.Statuses,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+	}
+	// One extra row tells whether there is a next page.
+	dbPage := database.TenantPage{
+		Sort:   query.Sort,
+		Desc:   query.Order == models.OrderDesc,
+		Offset: (page - 1) * perPage,
+		Limit:  perPage + 1,
+	}
+	if query.Cursor != nil {
+		page = 0
+		if query.Cursor.ID != 0 {
+			key, err := cursorKey(query.Cursor)
+			if err != nil {
+				return nil, models.PaginationMeta{}, fmt.Errorf("invalid query: %w", err)
+			}
+			dbPage.After = key
+		}
 	}
 
-	dbTenants, total, err := database.GetAllTenants(page, perPage)
+	dbTenants, total, err := database.GetAllTenants(filter, dbPage)
 	if err != nil {
 		return nil, models.PaginationMeta{}, fmt.Errorf("failed to get tenants: %w", err)
 	}
 
+	nextCursor := ""
+	if len(dbTenants) > perPage {
+		dbTenants = dbTenants[:perPage]
+		nextCursor = tenantCursor(&dbTenants[perPage-1], query.Sort, query.Order).Encode()
+	}
+
+	names := make([]string, 0, len(dbTenants))
+	for _, dbTenant := range dbTenants {
+		names = append(names, dbTenant.Name)
+	}
+	labels, err := database.GetLabelsForTenants(names)
+	if err != nil {
+		return nil, models.PaginationMeta{}, err
+	}
+
 	tenants := make([]models.Tenant, 0, len(dbTenants))
 	for _, dbTenant := range dbTenants {
-		status := dbTenant.Status
-		if s.dockerClient.ContainerExists(ctx, dbTenant.ContainerName) {
-			containerStatus, err := s.dockerClient.InspectContainer(ctx, dbTenant.ContainerName)
-			if err == nil {
-				status = containerStatus
-				if status != dbTenant.Status {
-					database.UpdateTenantStatus(dbTenant.Name, status)
-				}
-			}
-		}
+		status := s.syncStatus(ctx, &dbTenant)
 
-		tenant := models.Tenant{
-			ID:            int(dbTenant.ID),
-			Name:          dbTenant.Name,
-			Port:          dbTenant.Port,
-			ContainerName: dbTenant.ContainerName,
-			VolumeName:    dbTenant.VolumeName,
-			Status:        status,
-			URL:           fmt.Sprintf("http://localhost:%d", dbTenant.Port),
-			Username:      "admin",
-			CreatedAt:     dbTenant.CreatedAt,
-			UpdatedAt:     dbTenant.UpdatedAt,
+		username := ""
+		if app, err := s.app(dbTenant.App); err == nil {
+			username = app.Username
 		}
-		tenants = append(tenants, tenant)
+
+		tenant := s.toTenant(&dbTenant, status, username, "")
+		tenant.Labels = labels[dbTenant.Name]
+		tenants = append(tenants, *tenant)
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
@@ -194,6 +392,8 @@ func (s *TenantService) ListTenants(ctx context.Context, page, perPage int) ([]m
 		Page:    page,
 		PerPage: perPage,
 		MaxPage: totalPages,
+
+		NextCursor: nextCursor,
 	}
 
 	return tenants, meta, nil
@@ -205,46 +405,95 @@ func (s *TenantService) GetTenant(ctx context.Context, name string) (*models.Ten
 		return nil, fmt.Errorf("tenant not found: %w", err)
 	}
 
-	status := dbTenant.Status
-	if s.dockerClient.ContainerExists(ctx, dbTenant.ContainerName) {
-		containerStatus, err := s.dockerClient.InspectContainer(ctx, dbTenant.ContainerName)
-		if err == nil {
-			status = containerStatus
-			if status != dbTenant.Status {
-				database.UpdateTenantStatus(dbTenant.Name, status)
-			}
-		}
+	status := s.syncStatus(ctx, dbTenant)
+
+	username, password := "", ""
+	if app, err := s.app(dbTenant.App); err == nil {
+		username = app.Username
+	}
+	if isActiveStatus(status) {
+		username, password = s.credentials(ctx, dbTenant)
 	}
 
-	password := ""
-	if status == models.StatusRunning {
-		password, _ = s.dockerClient.GetContainerLogs(ctx, dbTenant.ContainerName)
+	health, _ := s.healthSummary(dbTenant.Name)
+
+	labels, err := database.GetTenantLabels(dbTenant.Name)
+	if err != nil {
+		return nil, err
 	}
 
-	tenant := &models.Tenant{
+	tenant := s.toTenant(dbTenant, status, username, password)
+	tenant.Labels = labels
+	tenant.Health = health
+
+	return tenant, nil
+}
+
+// toTenant builds the API view of a stored tenant. Labels and health are
+// filled in by the callers that load them.
+func (s *TenantService) toTenant(dbTenant *database.Tenant, status, username, password string) *models.Tenant {
+	return &models.Tenant{
 		ID:            int(dbTenant.ID),
 		Name:          dbTenant.Name,
 		Port:          dbTenant.Port,
 		ContainerName: dbTenant.ContainerName,
 		VolumeName:    dbTenant.VolumeName,
 		Status:        status,
-		URL:           fmt.Sprintf("http://localhost:%d", dbTenant.Port),
-		Username:      "admin",
+		App:           dbTenant.App,
+		Description:   dbTenant.Description,
+		URL:           s.tenantURL(dbTenant),
+		Username:      username,
 		Password:      password,
 		CreatedAt:     dbTenant.CreatedAt,
 		UpdatedAt:     dbTenant.UpdatedAt,
+
+		IdleTimeoutMinutes: dbTenant.IdleTimeoutMinutes,
+		LastActivityAt:     dbTenant.LastActivityAt,
+		CustomDomain:       dbTenant.CustomDomain,
+		Network:            dbTenant.Network,
+		Egress:             dbTenant.Egress,
+		Security:           securityProfile(dbTenant),
+		Image:              dbTenant.Image,
+		ImageDigest:        dbTenant.ImageDigest,
+		MemoryMB:           dbTenant.MemoryMB,
+		CPUs:               dbTenant.CPUs,
+		RestartPolicy:      dbTenant.RestartPolicy,
+		Env:                redactedEnv(dbTenant),
+		ClonedFrom:         dbTenant.ClonedFrom,
 	}
+}
 
-	return tenant, nil
+// redactedEnv returns the env of a tenant with its values masked, as they
+// often hold credentials.
+func redactedEnv(dbTenant *database.Tenant) map[string]string {
+	env := dbTenant.EnvMap()
+	for key, value := range env {
+		if value != "" {
+			env[key] = "********"
+		}
+	}
+	return env
 }
 
 func (s *TenantService) StopTenantContainer(ctx context.Context, name string) error {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := s.lockIdleTenant(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	dbTenant, err := database.GetTenantByName(name)
 	if err != nil {
 		return fmt.Errorf("tenant not found: %w", err)
 	}
 
-	if dbTenant.Status != models.StatusRunning {
+	if !isActiveStatus(dbTenant.Status) {
 		return fmt.Errorf("container is not running")
 	}
 
@@ -256,55 +505,73 @@ func (s *TenantService) StopTenantContainer(ctx context.Context, name string) er
 		return fmt.Errorf("failed to update tenant status: %w", err)
 	}
 
+	s.recordEvent(models.EventTenantStopped, name, "Container stopped", nil)
+
 	return nil
 }
 
 func (s *TenantService) StartTenantContainer(ctx context.Context, name string) error {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := s.lockIdleTenant(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	dbTenant, err := database.GetTenantByName(name)
 	if err != nil {
 		return fmt.Errorf("tenant not found: %w", err)
 	}
 
-	if dbTenant.Status == models.StatusRunning {
+	if isActiveStatus(dbTenant.Status) {
 		return fmt.Errorf("container is already running")
 	}
 
+	if dbTenant.Status == models.StatusHibernated {
+		s.disarmWakeListener(name)
+	}
+
 	if err := s.dockerClient.StartContainer(ctx, dbTenant.ContainerName); err != nil {
 		return fmt.Errorf("failed to start container: %w", err)
 	}
 
-	if err := database.UpdateTenantStatus(name, models.StatusRunning); err != nil {
+	fields := map[string]interface{}{
+		"status":           models.StatusRunning,
+		"last_activity_at": time.Now(),
+	}
+	if err := database.UpdateTenantFields(name, fields); err != nil {
 		return fmt.Errorf("failed to update tenant status: %w", err)
 	}
 
+	s.recordEvent(models.EventTenantStarted, name, "Container started", nil)
+
 	return nil
 }
 
 func (s *TenantService) DeleteTenant(ctx context.Context, name string) error {
-	dbTenant, err := database.GetTenantByName(name)
+	ctx, done, err := s.beginOperation(ctx)
 	if err != nil {
-		return fmt.Errorf("tenant not found: %w", err)
-	}
-
-	if err := s.dockerClient.RemoveContainer(ctx, dbTenant.ContainerName); err != nil {
-		fmt.Printf("Warning: failed to remove container: %v\n", err)
-	}
-
-	if err := s.dockerClient.RemoveVolume(ctx, dbTenant.VolumeName); err != nil {
-		fmt.Printf("Warning: failed to remove volume: %v\n", err)
+		return err
 	}
+	defer done()
 
-	tenantDir := filepath.Join(s.baseDir, "tenants", name)
-	if err := os.RemoveAll(tenantDir); err != nil {
-		fmt.Printf("Warning: failed to remove tenant directory: %v\n", err)
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return fmt.Errorf("tenant not found: %w", err)
 	}
 
-	if err := database.DeleteTenant(name); err != nil {
-		return fmt.Errorf("failed to delete tenant from database: %w", err)
+	op, err := s.startOperation(OperationDelete, dbTenant, filepath.Join(s.baseDir, "tenants", name))
+	if err != nil {
+		return fmt.Errorf("failed to journal tenant deletion: %w", err)
 	}
 
-	if err := s.UpdatePrometheusTargets(); err != nil {
-		fmt.Printf("Warning: failed to update Prometheus targets: %v\n", err)
+	if err := s.runDelete(ctx, op); err != nil {
+		return fmt.Errorf("failed to delete tenant, it will be retried on the next start: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"tenant-manager/utils"
+
+	"github.com/distribution/reference"
+)
+
+// UpdateTenant changes the settings of a tenant. Metadata, limits and the
+// restart policy are applied in place; env, the image tag and lifting a
+// limit need a new container, which is created in the background with the
+// same snapshot and rollback as an upgrade.
+func (s *TenantService) UpdateTenant(ctx context.Context, name string, req models.UpdateTenantRequest) (*models.UpdateTenantResult, error) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	if err := checkLabelCount(name, req.Labels); err != nil {
+		return nil, err
+	}
+
+	app, err := s.app(dbTenant.App)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := req.Env[app.BaseURLEnv]; ok && app.BaseURLEnv != "" {
+		return nil, fmt.Errorf("invalid env: %s is set by the tenant manager", app.BaseURLEnv)
+	}
+
+	result := &models.UpdateTenantResult{Applied: []string{}, Pending: []string{}}
+
+	// updated is the tenant as its next container will see it.
+	updated := *dbTenant
+	recreate := make([]string, 0)
+	live := make([]string, 0)
+	var update utils.ContainerUpdate
+
+	if req.ImageTag != nil {
+		base := dbTenant.Image
+		if base == "" {
+			base = s.runningImage(dbTenant)
+		}
+		target, err := withTag(base, *req.ImageTag)
+		if err != nil {
+			return nil, err
+		}
+		if target != dbTenant.Image {
+			updated.Image = target
+			recreate = append(recreate, "image_tag")
+		}
+	}
+
+	if len(req.Env) > 0 {
+		env := dbTenant.EnvMap()
+		for key, value := range req.Env {
+			if value == nil {
+				delete(env, key)
+			} else {
+				env[key] = *value
+			}
+		}
+		if len(env) > models.MaxTenantEnv {
+			return nil, fmt.Errorf("invalid env: a tenant can have at most %d variables", models.MaxTenantEnv)
+		}
+		updated.SetEnv(env)
+		if updated.Env != dbTenant.Env {
+			recreate = append(recreate, "env")
+		}
+	}
+
+	if req.MemoryMB != nil && *req.MemoryMB != dbTenant.MemoryMB {
+		updated.MemoryMB = *req.MemoryMB
+		if *req.MemoryMB == 0 {
+			recreate = append(recreate, "memory_mb")
+		} else {
+			update.MemoryMB = *req.MemoryMB
+			live = append(live, "memory_mb")
+		}
+	}
+
+	if req.CPUs != nil && *req.CPUs != dbTenant.CPUs {
+		updated.CPUs = *req.CPUs
+		if *req.CPUs == 0 {
+			recreate = append(recreate, "cpus")
+		} else {
+			update.CPUs = *req.CPUs
+			live = append(live, "cpus")
+		}
+	}
+
+	if req.RestartPolicy != nil && *req.RestartPolicy != dbTenant.RestartPolicy {
+		updated.RestartPolicy = *req.RestartPolicy
+		update.RestartPolicy = *req.RestartPolicy
+		live = append(live, "restart_policy")
+	}
+
+	if len(recreate) > 0 {
+		if !isActiveStatus(dbTenant.Status) {
+			return nil, fmt.Errorf("tenant must be running to change %v, it is %s", recreate, dbTenant.Status)
+		}
+		if err := checkNoPendingOperation(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Everything that can fail without side effects happens first: the
+	// recreation is journaled before any change is made, and undone if a
+	// later step fails.
+	var recreation *pendingRecreate
+	if len(recreate) > 0 {
+		recreation, err = s.prepareRecreate(ctx, dbTenant, &updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+	abort := func(err error) (*models.UpdateTenantResult, error) {
+		if recreation != nil {
+			recreation.cancel(err)
+		}
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	updatedLive := false
+	if len(live) > 0 {
+		if s.dockerClient.ContainerExists(ctx, dbTenant.ContainerName) {
+			if err := s.dockerClient.UpdateContainer(ctx, dbTenant.ContainerName, update); err != nil {
+				return abort(err)
+			}
+			updatedLive = true
+			result.Applied = append(result.Applied, live...)
+		} else {
+			// Taken into account when the container is next created.
+			result.Pending = append(result.Pending, live...)
+		}
+
+		for _, field := range live {
+			switch field {
+			case "memory_mb":
+				fields[field] = updated.MemoryMB
+			case "cpus":
+				fields[field] = updated.CPUs
+			case "restart_policy":
+				fields[field] = updated.RestartPolicy
+			}
+		}
+	}
+	if req.Description != nil {
+		fields["description"] = *req.Description
+		result.Applied = append(result.Applied, "description")
+	}
+	if len(req.Labels) > 0 {
+		result.Applied = append(result.Applied, "labels")
+	}
+
+	if err := database.UpdateTenantWithLabels(name, fields, req.Labels); err != nil {
+		if updatedLive {
+			s.revertContainerUpdate(ctx, dbTenant, live)
+		}
+		return abort(err)
+	}
+	s.invalidateDomainCache()
+
+	if recreation != nil {
+		// The previous container keeps the live changes that were just
+		// recorded.
+		recreation.previous.MemoryMB = updated.MemoryMB
+		recreation.previous.CPUs = updated.CPUs
+		recreation.previous.RestartPolicy = updated.RestartPolicy
+		for _, field := range recreate {
+			switch field {
+			case "memory_mb":
+				recreation.previous.MemoryMB = dbTenant.MemoryMB
+			case "cpus":
+				recreation.previous.CPUs = dbTenant.CPUs
+			}
+		}
+		recreation.start(s, recreate)
+		result.Pending = append(result.Pending, recreate...)
+	}
+
+	result.Tenant, err = s.GetTenant(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func checkLabelCount(name string, labels map[string]*string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	current, err := database.GetTenantLabels(name)
+	if err != nil {
+		return err
+	}
+	count := len(current)
+	for key, value := range labels {
+		_, exists := current[key]
+		switch {
+		case value == nil && exists:
+			count--
+		case value != nil && !exists:
+			count++
+		}
+	}
+	if count > models.MaxTenantLabels {
+		return fmt.Errorf("invalid labels: a tenant can have at most %d labels", models.MaxTenantLabels)
+	}
+	return nil
+}
+
+// withTag replaces the tag or digest of an image reference.
+func withTag(image, tag string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	tagged, err := reference.WithTag(reference.TrimNamed(named), tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid image tag %q: %w", tag, err)
+	}
+	return reference.FamiliarString(tagged), nil
+}
+
+// pendingRecreate is a journaled recreation that has not started yet.
+type pendingRecreate struct {
+	op       *database.Operation
+	ctx      context.Context
+	done     func()
+	status   string
+	previous database.Tenant
+	target   database.Tenant
+}
+
+// prepareRecreate resolves the new image and journals the recreation. The
+// tenant is marked upgrading until the new container is healthy or the
+// previous one has been put back.
+func (s *TenantService) prepareRecreate(ctx context.Context, dbTenant, updated *database.Tenant) (*pendingRecreate, error) {
+	if updated.Image != dbTenant.Image {
+		digest, err := s.dockerClient.ResolveImage(ctx, updated.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull image: %w", err)
+		}
+		updated.ImageDigest = digest
+	}
+
+	opCtx, done, err := s.beginOperation(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	op := &database.Operation{
+		Kind:          OperationUpgrade,
+		TenantName:    dbTenant.Name,
+		Step:          stepStarted,
+		Port:          dbTenant.Port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		Network:       dbTenant.Network,
+		Image:         updated.ImageDigest,
+		PreviousImage: s.runningImage(dbTenant),
+		Snapshot:      settingsSnapshotName(dbTenant.Name),
+		Settings:      containerSettings(updated),
+	}
+	check := tenantActive(dbTenant.Name, "change its container settings")
+	if err := s.reserveOperation(op, check, dbTenant.Name); err != nil {
+		done()
+		return nil, fmt.Errorf("failed to journal tenant recreation: %w", err)
+	}
+
+	if err := database.UpdateTenantStatus(dbTenant.Name, models.StatusUpgrading); err != nil {
+		database.FinishOperation(op, database.OperationCompensated, err)
+		done()
+		return nil, fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	return &pendingRecreate{
+		op:       op,
+		ctx:      opCtx,
+		done:     done,
+		status:   dbTenant.Status,
+		previous: *dbTenant,
+		target:   *updated,
+	}, nil
+}
+
+// cancel drops a recreation that never started.
+func (r *pendingRecreate) cancel(cause error) {
+	defer r.done()
+	if err := database.UpdateTenantStatus(r.op.TenantName, r.status); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.FinishOperation(r.op, database.OperationCompensated, cause); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (r *pendingRecreate) start(s *TenantService, changes []string) {
+	go func() {
+		defer r.done()
+		s.recreateTenant(r.ctx, &r.previous, &r.target, r.op, changes)
+	}()
+}
+
+// revertContainerUpdate puts back the limits and restart policy a failed
+// update changed. Docker cannot lift a limit in place, so limits that were
+// unlimited before stay as they are.
+func (s *TenantService) revertContainerUpdate(ctx context.Context, dbTenant *database.Tenant, changed []string) {
+	var revert utils.ContainerUpdate
+	for _, field := range changed {
+		switch field {
+		case "memory_mb":
+			revert.MemoryMB = dbTenant.MemoryMB
+		case "cpus":
+			revert.CPUs = dbTenant.CPUs
+		case "restart_policy":
+			revert.RestartPolicy = dbTenant.RestartPolicy
+		}
+	}
+	if err := s.dockerClient.UpdateContainer(context.WithoutCancel(ctx), dbTenant.ContainerName, revert); err != nil {
+		log.Printf("Warning: failed to revert container update of %s: %v", dbTenant.Name, err)
+	}
+}
+
+func (s *TenantService) recreateTenant(ctx context.Context, previous, updated *database.Tenant, op *database.Operation, changes []string) {
+	if err := s.replaceContainer(ctx, op, updated); err != nil {
+		if rollbackErr := s.rollbackUpgrade(ctx, previous, op, err); rollbackErr != nil {
+			log.Printf("Warning: recreating tenant %s failed (%v) and rollback failed: %v", op.TenantName, err, rollbackErr)
+		}
+		return
+	}
+
+	fields := map[string]interface{}{
+		"image":        updated.Image,
+		"image_digest": updated.ImageDigest,
+		"env":          updated.Env,
+		"memory_mb":    updated.MemoryMB,
+		"cpus":         updated.CPUs,
+		"status":       models.StatusRunning,
+	}
+	if err := database.UpdateTenantFields(op.TenantName, fields); err != nil {
+		log.Printf("Warning: tenant %s was recreated but could not be recorded: %v", op.TenantName, err)
+		return
+	}
+
+	s.finishUpgrade(ctx, op)
+
+	s.recordEvent(models.EventTenantUpgraded, op.TenantName, "Tenant recreated with new settings", map[string]interface{}{
+		"changes": changes,
+		"image":   s.runningImage(updated),
+	})
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"tenant-manager/database"
+	"tenant-manager/models"
+)
+
+const OperationUpgrade = "upgrade"
+
+const stepSnapshot = "snapshot"
+
+func settingsSnapshotName(name string) string {
+	return name + "_settings_snapshot"
+}
+
+// runningImage is the image reference the tenant container runs today.
+func (s *TenantService) runningImage(dbTenant *database.Tenant) string {
+	if image := tenantImage(dbTenant); image != "" {
+		return image
+	}
+	if app, err := s.app(dbTenant.App); err == nil {
+		return s.appImage(app)
+	}
+	return s.cfg().DockerImage
+}
+
+// containerSettings serializes the tenant fields that are baked into its
+// container and can only change by recreating it.
+func containerSettings(dbTenant *database.Tenant) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"env":       dbTenant.EnvMap(),
+		"memory_mb": dbTenant.MemoryMB,
+		"cpus":      dbTenant.CPUs,
+	})
+	return string(data)
+}
+
+// UpgradeTenant moves a tenant to another image. The settings volume is
+// snapshotted while the container is stopped, and the previous image and
+// snapshot are restored when the new container does not become healthy.
+func (s *TenantService) UpgradeTenant(ctx context.Context, name string, req models.UpgradeTenantRequest) (*models.UpgradeResult, error) {
+	ctx, done, err := s.beginOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	if !isActiveStatus(dbTenant.Status) {
+		return nil, fmt.Errorf("tenant must be running to upgrade, it is %s", dbTenant.Status)
+	}
+
+	target := req.Image
+	if target == "" {
+		app, err := s.app(dbTenant.App)
+		if err != nil {
+			return nil, err
+		}
+		target = s.appImage(app)
+	}
+
+	digest, err := s.dockerClient.ResolveImage(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull target image: %w", err)
+	}
+
+	result := &models.UpgradeResult{
+		Tenant:              name,
+		PreviousImage:       dbTenant.Image,
+		PreviousImageDigest: dbTenant.ImageDigest,
+		Image:               target,
+		ImageDigest:         digest,
+	}
+	if digest == tenantImage(dbTenant) {
+		return result, nil
+	}
+	result.Changed = true
+
+	op := &database.Operation{
+		Kind:          OperationUpgrade,
+		TenantName:    name,
+		Step:          stepStarted,
+		Port:          dbTenant.Port,
+		ContainerName: dbTenant.ContainerName,
+		VolumeName:    dbTenant.VolumeName,
+		Network:       dbTenant.Network,
+		Image:         digest,
+		PreviousImage: s.runningImage(dbTenant),
+		Snapshot:      settingsSnapshotName(name),
+		Settings:      containerSettings(dbTenant),
+	}
+	if err := s.reserveOperation(op, tenantActive(name, "upgrade"), name); err != nil {
+		return nil, fmt.Errorf("failed to journal tenant upgrade: %w", err)
+	}
+
+	if err := database.UpdateTenantStatus(name, models.StatusUpgrading); err != nil {
+		database.FinishOperation(op, database.OperationCompensated, err)
+		return nil, fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	upgraded := *dbTenant
+	upgraded.Image = target
+	upgraded.ImageDigest = digest
+
+	if err := s.replaceContainer(ctx, op, &upgraded); err != nil {
+		result.RolledBack = true
+		result.Error = err.Error()
+		if rollbackErr := s.rollbackUpgrade(ctx, dbTenant, op, err); rollbackErr != nil {
+			result.RolledBack = false
+			return result, fmt.Errorf("upgrade failed (%v) and rollback failed: %w", err, rollbackErr)
+		}
+		return result, fmt.Errorf("upgrade failed and was rolled back: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"image":        upgraded.Image,
+		"image_digest": upgraded.ImageDigest,
+		"status":       models.StatusRunning,
+	}
+	if err := database.UpdateTenantFields(name, fields); err != nil {
+		return result, fmt.Errorf("tenant was upgraded but could not be recorded: %w", err)
+	}
+
+	s.finishUpgrade(ctx, op)
+
+	s.recordEvent(models.EventTenantUpgraded, name, "Tenant upgraded", map[string]interface{}{
+		"previous_image": op.PreviousImage,
+		"image":          digest,
+	})
+
+	return result, nil
+}
+
+func (s *TenantService) replaceContainer(ctx context.Context, op *database.Operation, upgraded *database.Tenant) error {
+	if err := s.dockerClient.StopContainer(ctx, op.ContainerName); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	if err := s.dockerClient.CopyVolume(ctx, op.PreviousImage, op.VolumeName, op.Snapshot); err != nil {
+		return fmt.Errorf("failed to snapshot settings volume: %w", err)
+	}
+	if err := database.UpdateOperationStep(op, stepSnapshot); err != nil {
+		return err
+	}
+
+	if err := s.dockerClient.RemoveContainer(ctx, op.ContainerName); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	if err := database.UpdateOperationStep(op, stepContainer); err != nil {
+		return err
+	}
+
+	spec, err := s.containerSpec(upgraded)
+	if err != nil {
+		return err
+	}
+	if _, err := s.dockerClient.CreateAndStartContainer(ctx, spec); err != nil {
+		return fmt.Errorf("failed to start upgraded container: %w", err)
+	}
+
+	if err := s.waitUntilReady(ctx, upgraded, s.cfg().UpgradeHealthTimeout); err != nil {
+		return fmt.Errorf("upgraded tenant is not healthy: %w", err)
+	}
+
+	return nil
+}
+
+// rollbackUpgrade puts the previous container back. The settings volume is
+// only restored when the snapshot was completed; before that it was never
+// touched.
+func (s *TenantService) rollbackUpgrade(ctx context.Context, dbTenant *database.Tenant, op *database.Operation, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	fail := func(err error) error {
+		database.RecordOperationError(op, err)
+		database.UpdateTenantStatus(op.TenantName, models.StatusError)
+		s.recordEvent(models.EventTenantUpgradeFailed, op.TenantName, "Upgrade failed and could not be rolled back", map[string]interface{}{
+			"image": op.Image,
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	if op.Step == stepSnapshot || op.Step == stepContainer {
+		if s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+			if err := s.dockerClient.RemoveContainer(ctx, op.ContainerName); err != nil {
+				return fail(err)
+			}
+		}
+		if err := s.dockerClient.CopyVolume(ctx, op.PreviousImage, op.Snapshot, op.VolumeName); err != nil {
+			return fail(fmt.Errorf("failed to restore settings snapshot: %w", err))
+		}
+	}
+
+	previous := *dbTenant
+	previous.ImageDigest = op.PreviousImage
+	if s.dockerClient.ContainerExists(ctx, op.ContainerName) {
+		if err := s.dockerClient.StartContainer(ctx, op.ContainerName); err != nil {
+			return fail(err)
+		}
+	} else {
+		spec, err := s.containerSpec(&previous)
+		if err != nil {
+			return fail(err)
+		}
+		if _, err := s.dockerClient.CreateAndStartContainer(ctx, spec); err != nil {
+			return fail(fmt.Errorf("failed to recreate previous container: %w", err))
+		}
+	}
+
+	if err := s.waitUntilReady(ctx, &previous, s.cfg().UpgradeHealthTimeout); err != nil {
+		log.Printf("Warning: tenant %s is not healthy after rolling back: %v", op.TenantName, err)
+	}
+
+	database.UpdateTenantStatus(op.TenantName, models.StatusRunning)
+	if s.dockerClient.VolumeExists(ctx, op.Snapshot) {
+		s.dockerClient.RemoveVolume(ctx, op.Snapshot)
+	}
+	database.FinishOperation(op, database.OperationCompensated, cause)
+
+	s.recordEvent(models.EventTenantUpgradeFailed, op.TenantName, "Upgrade failed and was rolled back", map[string]interface{}{
+		"image":          op.Image,
+		"previous_image": op.PreviousImage,
+		"error":          cause.Error(),
+	})
+	return nil
+}
+
+func (s *TenantService) finishUpgrade(ctx context.Context, op *database.Operation) {
+	if s.dockerClient.VolumeExists(ctx, op.Snapshot) {
+		if err := s.dockerClient.RemoveVolume(ctx, op.Snapshot); err != nil {
+			log.Printf("Warning: failed to remove settings snapshot of %s: %v", op.TenantName, err)
+		}
+	}
+	if err := database.FinishOperation(op, database.OperationCompleted, nil); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// recoverUpgrade completes an upgrade that was recorded on the tenant and
+// rolls back any other.
+func (s *TenantService) recoverUpgrade(ctx context.Context, op *database.Operation) error {
+	dbTenant, err := database.GetTenantByName(op.TenantName)
+	if err != nil {
+		return database.FinishOperation(op, database.OperationCompensated, err)
+	}
+
+	if dbTenant.ImageDigest == op.Image && (op.Settings == "" || op.Settings == containerSettings(dbTenant)) {
+		if dbTenant.Status == models.StatusUpgrading {
+			database.UpdateTenantStatus(op.TenantName, models.StatusRunning)
+		}
+		s.finishUpgrade(ctx, op)
+		return nil
+	}
+
+	return s.rollbackUpgrade(ctx, dbTenant, op, fmt.Errorf("interrupted after step %s", op.Step))
+}
+
+// tenantActive checks again, under the tenant lock, that a tenant is still
+// running before an operation that needs it running is journaled.
+func tenantActive(name, action string) func() error {
+	return func() error {
+		dbTenant, err := database.GetTenantByName(name)
+		if err != nil {
+			return fmt.Errorf("tenant not found: %w", err)
+		}
+		if !isActiveStatus(dbTenant.Status) {
+			return fmt.Errorf("tenant must be running to %s, it is %s", action, dbTenant.Status)
+		}
+		return nil
+	}
+}
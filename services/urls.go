@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"tenant-manager/database"
+)
+
+// tenantURL renders the public URL of a tenant. A custom domain wins over
+// TENANT_URL_TEMPLATE, which wins over the proxy or host-port defaults.
+// Templates support {name}, {host}, {port}, {domain} and {public_url}.
+func (s *TenantService) tenantURL(dbTenant *database.Tenant) string {
+	cfg := s.cfg()
+	if dbTenant.CustomDomain != nil && *dbTenant.CustomDomain != "" {
+		return fmt.Sprintf("%s://%s", cfg.CustomDomainScheme, *dbTenant.CustomDomain)
+	}
+
+	template := cfg.TenantURLTemplate
+	if template == "" {
+		template = s.defaultURLTemplate()
+	}
+
+	replacer := strings.NewReplacer(
+		"{name}", dbTenant.Name,
+		"{host}", cfg.PublicHost,
+		"{port}", strconv.Itoa(dbTenant.Port),
+		"{domain}", cfg.ProxyBaseDomain,
+		"{public_url}", strings.TrimRight(cfg.ProxyPublicURL, "/"),
+	)
+	return replacer.Replace(template)
+}
+
+func (s *TenantService) defaultURLTemplate() string {
+	cfg := s.cfg()
+	if !cfg.ProxyEnabled {
+		return "http://{host}:{port}"
+	}
+
+	public, err := url.Parse(cfg.ProxyPublicURL)
+	if err != nil {
+		return "http://{host}:{port}"
+	}
+
+	if cfg.ProxyMode != ProxyModePath && cfg.ProxyBaseDomain != "" {
+		host := "{name}.{domain}"
+		if port := public.Port(); port != "" {
+			host = host + ":" + port
+		}
+		return fmt.Sprintf("%s://%s", public.Scheme, host)
+	}
+
+	return "{public_url}" + ProxyPathPrefix + "{name}/"
+}
+
+func (s *TenantService) SetCustomDomain(ctx context.Context, name, domain string) (string, error) {
+	dbTenant, err := database.GetTenantByName(name)
+	if err != nil {
+		return "", fmt.Errorf("tenant not found: %w", err)
+	}
+
+	var value interface{}
+	if domain != "" {
+		if existing, err := database.GetTenantByCustomDomain(domain); err == nil && existing.Name != name {
+			return "", fmt.Errorf("domain is already assigned to tenant %s", existing.Name)
+		}
+		value = domain
+	}
+
+	if err := database.UpdateTenantFields(name, map[string]interface{}{"custom_domain": value}); err != nil {
+		return "", fmt.Errorf("failed to update custom domain: %w", err)
+	}
+	s.invalidateDomainCache()
+
+	if domain != "" {
+		dbTenant.CustomDomain = &domain
+	} else {
+		dbTenant.CustomDomain = nil
+	}
+
+	if err := s.UpdatePrometheusTargets(); err != nil {
+		fmt.Printf("Warning: failed to update Prometheus targets: %v\n", err)
+	}
+
+	return s.tenantURL(dbTenant), nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"tenant-manager/database"
+	"tenant-manager/models"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Tenant     string      `json:"tenant,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+type WebhookService struct {
+	httpClient  *http.Client
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	wg          sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewWebhookService() *WebhookService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookService{
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 5,
+		baseBackoff: 2 * time.Second,
+		maxBackoff:  2 * time.Minute,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// webhookMessage is an event as it is sent. Retries resend the same
+// payload, including after a restart.
+type webhookMessage struct {
+	eventID string
+	event   string
+	payload []byte
+}
+
+// Publish delivers the event asynchronously to every active subscription.
+func (w *WebhookService) Publish(eventType, tenantName string, data interface{}) {
+	webhooks, err := database.GetActiveWebhooksForEvent(eventType)
+	if err != nil {
+		log.Printf("Warning: failed to load webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	event := WebhookEvent{
+		ID:         newEventID(),
+		Type:       eventType,
+		Tenant:     tenantName,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+	msg, err := newWebhookMessage(event)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		w.wg.Add(1)
+		go func(webhook database.Webhook) {
+			defer w.wg.Done()
+			w.deliverWithRetry(w.ctx, webhook, msg, 1, 0)
+		}(webhook)
+	}
+}
+
+// ResumePending continues the retries that were still due when the
+// manager last stopped. Only the latest attempt of each delivery is
+// resumed.
+func (w *WebhookService) ResumePending() {
+	deliveries, err := database.GetPendingWebhookDeliveries()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	type deliveryKey struct {
+		webhookID uint
+		eventID   string
+	}
+	latest := make(map[deliveryKey]database.WebhookDelivery)
+	order := make([]deliveryKey, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		key := deliveryKey{delivery.WebhookID, delivery.EventID}
+		previous, ok := latest[key]
+		if !ok {
+			order = append(order, key)
+		} else if previous.Attempt > delivery.Attempt {
+			database.ClearWebhookRetry(delivery.ID)
+			continue
+		} else {
+			database.ClearWebhookRetry(previous.ID)
+		}
+		latest[key] = delivery
+	}
+
+	for _, key := range order {
+		delivery := latest[key]
+
+		webhook, err := database.GetWebhookByID(delivery.WebhookID)
+		if err != nil || !webhook.Active || delivery.Attempt >= w.maxAttempts {
+			database.ClearWebhookRetry(delivery.ID)
+			continue
+		}
+
+		msg := webhookMessage{eventID: delivery.EventID, event: delivery.Event, payload: []byte(delivery.Payload)}
+		w.wg.Add(1)
+		go func(webhook database.Webhook, delivery database.WebhookDelivery) {
+			defer w.wg.Done()
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(time.Until(*delivery.NextAttemptAt)):
+			}
+			w.deliverWithRetry(w.ctx, webhook, msg, delivery.Attempt+1, delivery.ID)
+		}(*webhook, delivery)
+	}
+}
+
+// TestFire sends a single synchronous webhook.test delivery.
+func (w *WebhookService) TestFire(ctx context.Context, webhook database.Webhook) (*database.WebhookDelivery, error) {
+	event := WebhookEvent{
+		ID:         newEventID(),
+		Type:       models.EventWebhookTest,
+		OccurredAt: time.Now().UTC(),
+		Data: map[string]interface{}{
+			"message": "This is a test delivery from the tenant manager",
+		},
+	}
+	msg, err := newWebhookMessage(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.deliver(ctx, webhook, msg, 1, nil)
+}
+
+// Wait blocks until all pending deliveries, including retries, are done.
+func (w *WebhookService) Wait() {
+	w.wg.Wait()
+}
+
+// Shutdown waits for pending deliveries until ctx expires and then
+// abandons the remaining retries. Abandoned retries stay recorded and
+// are resumed on the next start.
+func (w *WebhookService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return fmt.Errorf("abandoned pending webhook retries")
+	}
+}
+
+// deliverWithRetry makes attempts from attempt on until one succeeds or
+// maxAttempts is reached. Each failed attempt records when the next one is
+// due; previous is the attempt this one takes over from, if any.
+func (w *WebhookService) deliverWithRetry(ctx context.Context, webhook database.Webhook, msg webhookMessage, attempt int, previous uint) {
+	for ; attempt <= w.maxAttempts; attempt++ {
+		var retryAt *time.Time
+		if attempt < w.maxAttempts {
+			next := time.Now().Add(w.backoff(attempt))
+			retryAt = &next
+		}
+
+		delivery, err := w.deliver(ctx, webhook, msg, attempt, retryAt)
+		if previous != 0 {
+			if err := database.ClearWebhookRetry(previous); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		if err != nil {
+			log.Printf("Warning: webhook %d could not send %s: %v", webhook.ID, msg.event, err)
+			return
+		}
+		if delivery.Success {
+			return
+		}
+
+		if retryAt == nil {
+			log.Printf("Warning: webhook %d gave up on %s after %d attempts", webhook.ID, msg.event, attempt)
+			return
+		}
+		previous = delivery.ID
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(*retryAt)):
+		}
+	}
+}
+
+// backoff is the wait after a failed attempt: baseBackoff doubled for
+// every earlier attempt, up to maxBackoff.
+func (w *WebhookService) backoff(attempt int) time.Duration {
+	backoff := w.baseBackoff
+	for i := 1; i < attempt && backoff < w.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > w.maxBackoff {
+		backoff = w.maxBackoff
+	}
+	return backoff
+}
+
+func (w *WebhookService) deliver(ctx context.Context, webhook database.Webhook, msg webhookMessage, attempt int, retryAt *time.Time) (*database.WebhookDelivery, error) {
+	delivery := &database.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   msg.eventID,
+		Event:     msg.event,
+		Payload:   string(msg.payload),
+		Attempt:   attempt,
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(msg.payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tenant-manager-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, msg.event)
+	req.Header.Set(WebhookDeliveryHeader, msg.eventID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, msg.payload))
+
+	startedAt := time.Now()
+	resp, err := w.httpClient.Do(req)
+	delivery.DurationMs = time.Since(startedAt).Milliseconds()
+
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		delivery.StatusCode = resp.StatusCode
+		delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+		if !delivery.Success {
+			delivery.Error = fmt.Sprintf("receiver responded with %s", resp.Status)
+		}
+	}
+	if !delivery.Success {
+		delivery.NextAttemptAt = retryAt
+	}
+
+	if err := database.CreateWebhookDelivery(delivery); err != nil {
+		log.Printf("Warning: failed to record webhook delivery: %v", err)
+	}
+
+	return delivery, nil
+}
+
+func newWebhookMessage(event WebhookEvent) (webhookMessage, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return webhookMessage{}, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return webhookMessage{eventID: event.ID, event: event.Type, payload: payload}, nil
+}
+
+// SignWebhookPayload computes the signature receivers verify:
+// hex(HMAC-SHA256(secret, timestamp + "." + body)) prefixed with "sha256=".
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func GenerateWebhookSecret() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func newEventID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
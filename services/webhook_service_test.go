@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"tenant-manager/database"
+	"testing"
+	"time"
+)
+
+type receivedWebhook struct {
+	at        time.Time
+	header    http.Header
+	body      []byte
+	signature string
+}
+
+// webhookReceiver answers with the given status codes in turn and 200
+// once they run out.
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedWebhook) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []receivedWebhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		status := http.StatusOK
+		if len(received) < len(statuses) {
+			status = statuses[len(received)]
+		}
+		received = append(received, receivedWebhook{
+			at:        time.Now(),
+			header:    r.Header.Clone(),
+			body:      body,
+			signature: r.Header.Get(WebhookSignatureHeader),
+		})
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []receivedWebhook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedWebhook(nil), received...)
+	}
+}
+
+func setupWebhookTest(t *testing.T, url string) (*WebhookService, *database.Webhook) {
+	t.Helper()
+
+	if err := database.InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	webhook := &database.Webhook{URL: url, Events: "*", Secret: "s3cret", Active: true}
+	if err := database.CreateWebhook(webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	w := NewWebhookService()
+	w.baseBackoff = 20 * time.Millisecond
+	w.maxBackoff = time.Second
+	return w, webhook
+}
+
+func expectedSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSignsTimestampAndBody(t *testing.T) {
+	server, received := webhookReceiver(t)
+	w, webhook := setupWebhookTest(t, server.URL)
+
+	w.Publish("tenant.created", "alpha", map[string]interface{}{"port": 8001})
+	w.Wait()
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+
+	timestamp := request.header.Get(WebhookTimestampHeader)
+	if timestamp == "" {
+		t.Fatal("missing timestamp header")
+	}
+	if want := expectedSignature(webhook.Secret, timestamp, request.body); request.signature != want {
+		t.Errorf("signature = %q, want %q", request.signature, want)
+	}
+	if got := request.header.Get(WebhookEventHeader); got != "tenant.created" {
+		t.Errorf("event header = %q, want tenant.created", got)
+	}
+}
+
+func TestWebhookRetriesServerErrorsWithBackoff(t *testing.T) {
+	server, received := webhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	w, webhook := setupWebhookTest(t, server.URL)
+
+	w.Publish("tenant.created", "alpha", nil)
+	w.Wait()
+
+	requests := received()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+
+	deliveryID := requests[0].header.Get(WebhookDeliveryHeader)
+	for i, request := range requests {
+		if got := request.header.Get(WebhookDeliveryHeader); got != deliveryID {
+			t.Errorf("attempt %d has delivery %q, want %q", i+1, got, deliveryID)
+		}
+		if string(request.body) != string(requests[0].body) {
+			t.Errorf("attempt %d sent a different body", i+1)
+		}
+	}
+
+	if gap := requests[1].at.Sub(requests[0].at); gap < w.baseBackoff {
+		t.Errorf("first retry after %s, want at least %s", gap, w.baseBackoff)
+	}
+	if gap := requests[2].at.Sub(requests[1].at); gap < 2*w.baseBackoff {
+		t.Errorf("second retry after %s, want at least %s", gap, 2*w.baseBackoff)
+	}
+
+	deliveries, total, err := database.GetWebhookDeliveries(webhook.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("recorded %d deliveries, want 3", total)
+	}
+	if !deliveries[0].Success || deliveries[0].Attempt != 3 {
+		t.Errorf("last delivery = attempt %d success %v, want attempt 3 succeeded", deliveries[0].Attempt, deliveries[0].Success)
+	}
+
+	pending, err := database.GetPendingWebhookDeliveries()
+	if err != nil {
+		t.Fatalf("GetPendingWebhookDeliveries: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d deliveries still pending, want none", len(pending))
+	}
+}
+
+func TestWebhookResumesPendingRetries(t *testing.T) {
+	server, received := webhookReceiver(t)
+	w, webhook := setupWebhookTest(t, server.URL)
+
+	// A failed second attempt left behind by a previous run.
+	retryAt := time.Now().Add(-time.Second)
+	failed := &database.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       "evt-1",
+		Event:         "tenant.deleted",
+		Payload:       `{"id":"evt-1","type":"tenant.deleted"}`,
+		Attempt:       2,
+		StatusCode:    http.StatusServiceUnavailable,
+		NextAttemptAt: &retryAt,
+	}
+	if err := database.CreateWebhookDelivery(failed); err != nil {
+		t.Fatalf("CreateWebhookDelivery: %v", err)
+	}
+
+	w.ResumePending()
+	w.Wait()
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if string(requests[0].body) != failed.Payload {
+		t.Errorf("body = %s, want %s", requests[0].body, failed.Payload)
+	}
+	if got := requests[0].header.Get(WebhookDeliveryHeader); got != "evt-1" {
+		t.Errorf("delivery header = %q, want evt-1", got)
+	}
+
+	deliveries, _, err := database.GetWebhookDeliveries(webhook.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].Attempt != 3 || !deliveries[0].Success {
+		t.Fatalf("deliveries = %+v, want a successful third attempt", deliveries)
+	}
+	if deliveries[1].NextAttemptAt != nil {
+		t.Error("resumed attempt is still pending")
+	}
+}
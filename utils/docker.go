@@ -2,83 +2,333 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"regexp"
 	"strings"
+	"tenant-manager/config"
 	"time"
 
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
 
 type DockerClient struct {
-	cli *client.Client
+	cli      *client.Client
+	settings *config.Store
 }
 
-func NewDockerClient() (*DockerClient, error) {
+type ContainerState struct {
+	Status       string
+	Running      bool
+	Health       string
+	RestartCount int
+	ExitCode     int
+	OOMKilled    bool
+	Error        string
+	StartedAt    time.Time
+}
+
+// tenantHealthcheck probes the app's health path from inside the
+// container; apps without one get no Docker healthcheck.
+func tenantHealthcheck(port int, path string) *container.HealthConfig {
+	if path == "" {
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", fmt.Sprintf("wget -q --spider http://localhost:%d%s || exit 1", port, path)},
+		Interval:    30 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: 15 * time.Second,
+		Retries:     3,
+	}
+}
+
+func NewDockerClient(settings *config.Store) (*DockerClient, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 
-	return &DockerClient{cli: cli}, nil
+	return &DockerClient{cli: cli, settings: settings}, nil
+}
+
+// ContainerSpec describes everything needed to (re)create a tenant container.
+type ContainerSpec struct {
+	// Image defaults to the configured docker_image when empty.
+	Image      string
+	TenantName string
+	Port       int
+	// InternalPort is the port the app listens on inside the container.
+	InternalPort int
+	// Mounts bind host paths, or the settings volume by name, into the
+	// container.
+	Mounts      []Mount
+	HealthPath  string
+	Env         []string
+	PublishPort bool
+	// Network defaults to the shared docker_network when empty.
+	Network string
+	// Security is applied as is; the zero value runs as root with
+	// Docker's default profile.
+	Security ContainerSecurity
+	// Limits of zero leave the container unlimited.
+	MemoryMB int
+	CPUs     float64
+	// RestartPolicy defaults to unless-stopped when empty.
+	RestartPolicy string
+}
+
+// ContainerUpdate changes a running container in place. Zero values are
+// left unchanged; Docker cannot lift a limit without recreating.
+type ContainerUpdate struct {
+	MemoryMB      int
+	CPUs          float64
+	RestartPolicy string
+}
+
+func (u ContainerUpdate) resources() container.Resources {
+	var resources container.Resources
+	if u.MemoryMB > 0 {
+		resources.Memory = int64(u.MemoryMB) * 1024 * 1024
+		// No swap on top, so the limit is a hard one.
+		resources.MemorySwap = resources.Memory
+	}
+	if u.CPUs > 0 {
+		resources.NanoCPUs = int64(u.CPUs * 1e9)
+	}
+	return resources
+}
+
+type Mount struct {
+	Source string
+	Target string
+}
+
+// ContainerSecurity is the hardening applied to a tenant container.
+type ContainerSecurity struct {
+	UID             int
+	GID             int
+	CapDrop         []string
+	NoNewPrivileges bool
+	ReadOnlyRootfs  bool
+	Tmpfs           []string
+	SeccompProfile  string
+	AppArmorProfile string
+}
+
+func (sec ContainerSecurity) user() string {
+	return fmt.Sprintf("%d:%d", sec.UID, sec.GID)
+}
+
+func (sec ContainerSecurity) securityOpt() []string {
+	opts := make([]string, 0)
+	if sec.NoNewPrivileges {
+		opts = append(opts, "no-new-privileges:true")
+	}
+	if sec.SeccompProfile != "" {
+		opts = append(opts, "seccomp="+sec.SeccompProfile)
+	}
+	if sec.AppArmorProfile != "" {
+		opts = append(opts, "apparmor="+sec.AppArmorProfile)
+	}
+	return opts
+}
+
+func (dc *DockerClient) CreateAndStartContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	containerName, err := dc.CreateContainer(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+
+	if err := dc.cli.ContainerStart(ctx, containerName, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	return containerName, nil
 }
 
-func (dc *DockerClient) CreateAndStartContainer(ctx context.Context, tenantName string, port int, filesPath string, configPath string) (string, error) {
-	containerName := fmt.Sprintf("files_%s", tenantName)
-	volumeName := fmt.Sprintf("%s_settings_vol", tenantName)
+// CreateContainer creates the tenant container and its settings volume
+// without starting it.
+func (dc *DockerClient) CreateContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	containerName := fmt.Sprintf("files_%s", spec.TenantName)
+	volumeName := fmt.Sprintf("%s_settings_vol", spec.TenantName)
 
 	if err := dc.createVolume(ctx, volumeName); err != nil {
 		return "", fmt.Errorf("failed to create volume: %w", err)
 	}
 
-	if err := dc.ensureImage(ctx, "filebrowser/filebrowser"); err != nil {
+	cfg := dc.settings.Get()
+
+	imageName := spec.Image
+	if imageName == "" {
+		imageName = cfg.DockerImage
+	}
+
+	if err := dc.ensureImage(ctx, imageName, nil); err != nil {
 		return "", fmt.Errorf("failed to ensure image: %w", err)
 	}
 
-	containerPort := nat.Port("80/tcp")
-	hostBinding := nat.PortBinding{
-		HostIP:   "0.0.0.0",
-		HostPort: fmt.Sprintf("%d", port),
+	sec := spec.Security
+	if sec.UID != 0 {
+		if err := dc.chownVolume(ctx, imageName, volumeName, sec.user()); err != nil {
+			return "", err
+		}
+	}
+
+	networkName := spec.Network
+	if networkName == "" {
+		networkName = cfg.DockerNetwork
+	}
+
+	containerPort := nat.Port(fmt.Sprintf("%d/tcp", spec.InternalPort))
+	portBindings := nat.PortMap{}
+	if spec.PublishPort {
+		portBindings[containerPort] = []nat.PortBinding{{
+			HostIP:   "0.0.0.0",
+			HostPort: fmt.Sprintf("%d", spec.Port),
+		}}
 	}
 
 	config := &container.Config{
-		Image: "filebrowser/filebrowser",
+		Image: imageName,
 		ExposedPorts: nat.PortSet{
 			containerPort: struct{}{},
 		},
-		User: "0:0",
+		User:        sec.user(),
+		Env:         spec.Env,
+		Healthcheck: tenantHealthcheck(spec.InternalPort, spec.HealthPath),
+	}
+
+	binds := make([]string, 0, len(spec.Mounts))
+	for _, mount := range spec.Mounts {
+		binds = append(binds, fmt.Sprintf("%s:%s:rw", mount.Source, mount.Target))
 	}
 
 	hostConfig := &container.HostConfig{
-		PortBindings: nat.PortMap{
-			containerPort: []nat.PortBinding{hostBinding},
-		},
-		Binds: []string{
-			fmt.Sprintf("%s:/srv:rw", filesPath),
-			fmt.Sprintf("%s:/database:rw", volumeName),
-			fmt.Sprintf("%s:/config:rw", configPath),
-		},
-		NetworkMode: container.NetworkMode("monitoring"),
-		RestartPolicy: container.RestartPolicy{
-			Name: container.RestartPolicyUnlessStopped,
-		},
+		PortBindings:   portBindings,
+		Binds:          binds,
+		NetworkMode:    container.NetworkMode(networkName),
+		RestartPolicy:  restartPolicy(spec.RestartPolicy),
+		Resources:      ContainerUpdate{MemoryMB: spec.MemoryMB, CPUs: spec.CPUs}.resources(),
+		CapDrop:        sec.CapDrop,
+		SecurityOpt:    sec.securityOpt(),
+		ReadonlyRootfs: sec.ReadOnlyRootfs,
+	}
+	if sec.UID != 0 && spec.InternalPort < 1024 {
+		// Lets the unprivileged app process bind a privileged port.
+		hostConfig.Sysctls = map[string]string{"net.ipv4.ip_unprivileged_port_start": "0"}
+	}
+	if len(sec.Tmpfs) > 0 {
+		hostConfig.Tmpfs = make(map[string]string, len(sec.Tmpfs))
+		for _, path := range sec.Tmpfs {
+			hostConfig.Tmpfs[path] = "rw,noexec,nosuid,size=64m"
+		}
 	}
 
-	resp, err := dc.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, containerName)
-	if err != nil {
+	if _, err := dc.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, containerName); err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
+	return containerName, nil
+}
+
+func restartPolicy(name string) container.RestartPolicy {
+	if name == "" {
+		return container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}
+	}
+	return container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+}
+
+// UpdateContainer applies new limits or restart policy without restarting
+// the container.
+func (dc *DockerClient) UpdateContainer(ctx context.Context, containerName string, update ContainerUpdate) error {
+	updateConfig := container.UpdateConfig{Resources: update.resources()}
+	if update.RestartPolicy != "" {
+		updateConfig.RestartPolicy = restartPolicy(update.RestartPolicy)
+	}
+
+	resp, err := dc.cli.ContainerUpdate(ctx, containerName, updateConfig)
+	if err != nil {
+		return fmt.Errorf("failed to update container: %w", err)
+	}
+	for _, warning := range resp.Warnings {
+		log.Printf("Warning: updating %s: %s", containerName, warning)
+	}
+	return nil
+}
+
+// chownVolume hands a named volume to the tenant user.
+func (dc *DockerClient) chownVolume(ctx context.Context, imageName, volumeName, owner string) error {
+	return dc.runHelper(ctx, imageName, []string{"chown", "-R", owner, "/target"},
+		fmt.Sprintf("%s:/target:rw", volumeName))
+}
+
+// CopyVolume replaces the contents of one named volume with those of
+// another. Both volumes should be unused while copying.
+func (dc *DockerClient) CopyVolume(ctx context.Context, imageName, from, to string) error {
+	if err := dc.createVolume(ctx, to); err != nil {
+		return err
+	}
+	return dc.copyInto(ctx, imageName, from, to)
+}
+
+// CopyDirectory replaces the contents of one host directory with those of
+// another, keeping ownership and modes. Both must exist.
+func (dc *DockerClient) CopyDirectory(ctx context.Context, imageName, from, to string) error {
+	return dc.copyInto(ctx, imageName, from, to)
+}
+
+// ChownDirectory hands a host directory and everything in it to uid:gid.
+func (dc *DockerClient) ChownDirectory(ctx context.Context, imageName, path string, uid, gid int) error {
+	return dc.chownVolume(ctx, imageName, path, fmt.Sprintf("%d:%d", uid, gid))
+}
+
+func (dc *DockerClient) copyInto(ctx context.Context, imageName, from, to string) error {
+	return dc.runHelper(ctx, imageName,
+		[]string{"sh", "-c", "find /to -mindepth 1 -delete && cp -a /from/. /to/"},
+		fmt.Sprintf("%s:/from:ro", from),
+		fmt.Sprintf("%s:/to:rw", to))
+}
+
+// runHelper runs a throwaway root container of the given image with the
+// binds and waits for it to exit successfully. The tenant image is used
+// so no extra image has to be pulled.
+func (dc *DockerClient) runHelper(ctx context.Context, imageName string, cmd []string, binds ...string) error {
+	resp, err := dc.cli.ContainerCreate(ctx, &container.Config{
+		Image:      imageName,
+		User:       "0:0",
+		Entrypoint: cmd,
+	}, &container.HostConfig{
+		Binds:       binds,
+		NetworkMode: "none",
+	}, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create helper container: %w", err)
+	}
+	defer dc.cli.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
+
 	if err := dc.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("failed to start container: %w", err)
+		return fmt.Errorf("failed to start helper container: %w", err)
 	}
 
-	return containerName, nil
+	statusCh, errCh := dc.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for helper container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("helper container %q exited with code %d", strings.Join(cmd, " "), status.StatusCode)
+		}
+	}
+	return nil
 }
 
 func (dc *DockerClient) createVolume(ctx context.Context, volumeName string) error {
@@ -103,24 +353,31 @@ func (dc *DockerClient) createVolume(ctx context.Context, volumeName string) err
 	return nil
 }
 
-func (dc *DockerClient) ensureImage(ctx context.Context, imageName string) error {
-	_, err := dc.cli.ImageInspect(ctx, imageName)
-	if err != nil {
-		return fmt.Errorf("image not found: %w", err)
+// ResolveImage makes sure the image is available and returns a reference
+// pinned to its digest. Images that were never pushed to a registry have
+// no repo digest and are pinned by image ID instead.
+func (dc *DockerClient) ResolveImage(ctx context.Context, imageName string) (string, error) {
+	if err := dc.ensureImage(ctx, imageName, nil); err != nil {
+		return "", fmt.Errorf("failed to ensure image: %w", err)
 	}
 
-	reader, err := dc.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	inspect, err := dc.cli.ImageInspect(ctx, imageName)
 	if err != nil {
-		return fmt.Errorf("failed to pull image: %w", err)
+		return "", fmt.Errorf("failed to inspect image: %w", err)
 	}
-	defer reader.Close()
 
-	_, err = io.Copy(io.Discard, reader)
-	if err != nil {
-		return fmt.Errorf("failed to read image pull output: %w", err)
+	if named, err := reference.ParseNormalizedNamed(imageName); err == nil {
+		for _, repoDigest := range inspect.RepoDigests {
+			pinned, err := reference.ParseNormalizedNamed(repoDigest)
+			if err == nil && pinned.Name() == named.Name() {
+				return repoDigest, nil
+			}
+		}
 	}
-
-	return nil
+	if len(inspect.RepoDigests) > 0 {
+		return inspect.RepoDigests[0], nil
+	}
+	return inspect.ID, nil
 }
 
 func (dc *DockerClient) StartContainer(ctx context.Context, containerName string) error {
@@ -131,7 +388,7 @@ func (dc *DockerClient) StartContainer(ctx context.Context, containerName string
 }
 
 func (dc *DockerClient) StopContainer(ctx context.Context, containerName string) error {
-	timeout := 10
+	timeout := dc.stopTimeout()
 	stopOptions := container.StopOptions{
 		Timeout: &timeout,
 	}
@@ -141,6 +398,28 @@ func (dc *DockerClient) StopContainer(ctx context.Context, containerName string)
 	return nil
 }
 
+func (dc *DockerClient) RestartContainer(ctx context.Context, containerName string) error {
+	timeout := dc.stopTimeout()
+	stopOptions := container.StopOptions{
+		Timeout: &timeout,
+	}
+	if err := dc.cli.ContainerRestart(ctx, containerName, stopOptions); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+	return nil
+}
+
+func (dc *DockerClient) stopTimeout() int {
+	return int(dc.settings.Get().StopTimeout.Seconds())
+}
+
+func (dc *DockerClient) RenameContainer(ctx context.Context, containerName, newName string) error {
+	if err := dc.cli.ContainerRename(ctx, containerName, newName); err != nil {
+		return fmt.Errorf("failed to rename container: %w", err)
+	}
+	return nil
+}
+
 func (dc *DockerClient) RemoveContainer(ctx context.Context, containerName string) error {
 	_ = dc.StopContainer(ctx, containerName)
 
@@ -160,8 +439,15 @@ func (dc *DockerClient) RemoveVolume(ctx context.Context, volumeName string) err
 	return nil
 }
 
-func (dc *DockerClient) GetContainerLogs(ctx context.Context, containerName string) (string, error) {
-	time.Sleep(2 * time.Second)
+func (dc *DockerClient) VolumeExists(ctx context.Context, volumeName string) bool {
+	_, err := dc.cli.VolumeInspect(ctx, volumeName)
+	return err == nil
+}
+
+// GetContainerLogs returns the first capture group of pattern in the
+// container logs, e.g. the generated initial password.
+func (dc *DockerClient) GetContainerLogs(ctx context.Context, containerName, pattern string) (string, error) {
+	time.Sleep(dc.settings.Get().LogWait)
 
 	options := container.LogsOptions{
 		ShowStdout: true,
@@ -181,7 +467,10 @@ func (dc *DockerClient) GetContainerLogs(ctx context.Context, containerName stri
 
 	logContent := string(logBytes)
 
-	re := regexp.MustCompile(`generated password:\s+(\S+)`)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid credential pattern: %w", err)
+	}
 	matches := re.FindStringSubmatch(logContent)
 
 	if len(matches) < 2 {
@@ -208,11 +497,146 @@ func (dc *DockerClient) InspectContainer(ctx context.Context, containerName stri
 	return containerJSON.State.Status, nil
 }
 
+func (dc *DockerClient) InspectContainerState(ctx context.Context, containerName string) (*ContainerState, error) {
+	containerJSON, err := dc.cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	state := &ContainerState{
+		RestartCount: containerJSON.RestartCount,
+	}
+	if containerJSON.State == nil {
+		return state, nil
+	}
+
+	state.Status = containerJSON.State.Status
+	state.Running = containerJSON.State.Running
+	state.ExitCode = containerJSON.State.ExitCode
+	state.OOMKilled = containerJSON.State.OOMKilled
+	state.Error = containerJSON.State.Error
+	state.StartedAt, _ = time.Parse(time.RFC3339Nano, containerJSON.State.StartedAt)
+	if containerJSON.State.Health != nil {
+		state.Health = containerJSON.State.Health.Status
+	}
+
+	return state, nil
+}
+
+// ContainerNetworkBytes returns the total bytes received and sent by the
+// container across all of its networks.
+func (dc *DockerClient) ContainerNetworkBytes(ctx context.Context, containerName string) (uint64, uint64, error) {
+	stats, err := dc.cli.ContainerStatsOneShot(ctx, containerName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer stats.Body.Close()
+
+	var response container.StatsResponse
+	if err := json.NewDecoder(stats.Body).Decode(&response); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	var rx, tx uint64
+	for _, network := range response.Networks {
+		rx += network.RxBytes
+		tx += network.TxBytes
+	}
+
+	return rx, tx, nil
+}
+
 func (dc *DockerClient) ContainerExists(ctx context.Context, containerName string) bool {
 	_, err := dc.cli.ContainerInspect(ctx, containerName)
 	return err == nil
 }
 
+// ListContainerNames returns the names of all containers, running or not,
+// whose name starts with prefix.
+func (dc *DockerClient) ListContainerNames(ctx context.Context, prefix string) ([]string, error) {
+	containers, err := dc.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", "^/"+prefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		for _, name := range c.Names {
+			name = strings.TrimPrefix(name, "/")
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+func (dc *DockerClient) ListVolumeNames(ctx context.Context) ([]string, error) {
+	volumes, err := dc.cli.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	names := make([]string, 0, len(volumes.Volumes))
+	for _, vol := range volumes.Volumes {
+		names = append(names, vol.Name)
+	}
+	return names, nil
+}
+
+func (dc *DockerClient) ExecAttach(ctx context.Context, containerName string, cmd []string, rows, cols uint) (string, types.HijackedResponse, error) {
+	execOptions := container.ExecOptions{
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+		Env:          []string{"TERM=xterm-256color"},
+	}
+	if rows > 0 && cols > 0 {
+		execOptions.ConsoleSize = &[2]uint{rows, cols}
+	}
+
+	execResp, err := dc.cli.ContainerExecCreate(ctx, containerName, execOptions)
+	if err != nil {
+		return "", types.HijackedResponse{}, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attachOptions := container.ExecAttachOptions{
+		Tty:         true,
+		ConsoleSize: execOptions.ConsoleSize,
+	}
+	hijacked, err := dc.cli.ContainerExecAttach(ctx, execResp.ID, attachOptions)
+	if err != nil {
+		return "", types.HijackedResponse{}, fmt.Errorf("failed to attach exec: %w", err)
+	}
+
+	return execResp.ID, hijacked, nil
+}
+
+func (dc *DockerClient) ExecResize(ctx context.Context, execID string, rows, cols uint) error {
+	if err := dc.cli.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: rows, Width: cols}); err != nil {
+		return fmt.Errorf("failed to resize exec: %w", err)
+	}
+	return nil
+}
+
+// ExecExitCode reports the exit code of an exec, or that it is still
+// running.
+func (dc *DockerClient) ExecExitCode(ctx context.Context, execID string) (int, bool, error) {
+	inspect, err := dc.cli.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return -1, false, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspect.Running {
+		return -1, true, nil
+	}
+	return inspect.ExitCode, false, nil
+}
+
 func (dc *DockerClient) Close() error {
 	if dc.cli != nil {
 		return dc.cli.Close()
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// PullProgress is one status line of an image pull, e.g. a layer download.
+type PullProgress struct {
+	Status  string `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// PullProgressFunc receives the progress of a pull; it may be nil.
+type PullProgressFunc func(PullProgress)
+
+type jsonMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	Stream         string `json:"stream"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// ensureImage applies the configured pull policy. References pinned by
+// digest or image ID are never pulled again once present.
+func (dc *DockerClient) ensureImage(ctx context.Context, imageName string, progress PullProgressFunc) error {
+	present, err := dc.imagePresent(ctx, imageName)
+	if err != nil {
+		return err
+	}
+
+	switch policy := dc.settings.Get().ImagePullPolicy; {
+	case present && (policy != "always" || isPinned(imageName)):
+		return nil
+	case policy == "never":
+		if present {
+			return nil
+		}
+		return fmt.Errorf("image %s is not present and image_pull_policy is never", imageName)
+	}
+
+	return dc.PullImage(ctx, imageName, progress)
+}
+
+func (dc *DockerClient) imagePresent(ctx context.Context, imageName string) (bool, error) {
+	_, err := dc.cli.ImageInspect(ctx, imageName)
+	if err == nil {
+		return true, nil
+	}
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to inspect image: %w", err)
+}
+
+// isPinned reports whether the reference names immutable content.
+func isPinned(imageName string) bool {
+	if strings.HasPrefix(imageName, "sha256:") {
+		return true
+	}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.Canonical)
+	return ok
+}
+
+// PullImage pulls the image regardless of the pull policy, using the
+// registry credentials configured for its host, and reports progress.
+func (dc *DockerClient) PullImage(ctx context.Context, imageName string, progress PullProgressFunc) error {
+	if strings.HasPrefix(imageName, "sha256:") {
+		return fmt.Errorf("image %s is not present and cannot be pulled by ID", imageName)
+	}
+
+	opts := image.PullOptions{}
+	auth, err := dc.registryAuth(imageName)
+	if err != nil {
+		return err
+	}
+	opts.RegistryAuth = auth
+
+	log.Printf("Pulling image %s", imageName)
+	reader, err := dc.cli.ImagePull(ctx, imageName, opts)
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	defer reader.Close()
+
+	if err := readJSONMessages(reader, func(msg jsonMessage) {
+		if progress != nil && msg.Status != "" {
+			progress(PullProgress{
+				Status:  msg.Status,
+				ID:      msg.ID,
+				Current: msg.ProgressDetail.Current,
+				Total:   msg.ProgressDetail.Total,
+			})
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	return nil
+}
+
+// registryAuth returns the encoded credentials for the image's registry,
+// or "" when none are configured.
+func (dc *DockerClient) registryAuth(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference: %w", err)
+	}
+	domain := registryHost(reference.Domain(named))
+
+	for _, auth := range dc.settings.Get().RegistryAuth {
+		if registryHost(auth.Server) != domain {
+			continue
+		}
+		encoded, err := registry.EncodeAuthConfig(registry.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			ServerAddress: auth.Server,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode registry credentials: %w", err)
+		}
+		return encoded, nil
+	}
+	return "", nil
+}
+
+// registryHost normalizes a registry address so "https://index.docker.io/v1/"
+// and "docker.io" compare equal.
+func registryHost(server string) string {
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "/"))
+
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return host
+}
+
+// LoadImage imports an image tarball as produced by "docker save" and
+// returns the references it contained.
+func (dc *DockerClient) LoadImage(ctx context.Context, tarball io.Reader) ([]string, error) {
+	resp, err := dc.cli.ImageLoad(ctx, tarball, client.ImageLoadWithQuiet(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	loaded := make([]string, 0)
+	if err := readJSONMessages(resp.Body, func(msg jsonMessage) {
+		for _, prefix := range []string{"Loaded image: ", "Loaded image ID: "} {
+			if ref, ok := strings.CutPrefix(strings.TrimSpace(msg.Stream), prefix); ok {
+				loaded = append(loaded, ref)
+			}
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+
+	if len(loaded) == 0 {
+		return nil, fmt.Errorf("failed to load image: the tarball contained no images")
+	}
+	return loaded, nil
+}
+
+// readJSONMessages decodes the progress stream of a pull or load and
+// returns the first error reported by the daemon.
+func readJSONMessages(r io.Reader, handle func(jsonMessage)) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read daemon output: %w", err)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		handle(msg)
+	}
+}
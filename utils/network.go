@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// EnsureNetwork creates a bridge network unless it already exists. An
+// internal network has no route to the outside world.
+func (dc *DockerClient) EnsureNetwork(ctx context.Context, name string, internal bool) error {
+	if _, err := dc.cli.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+		return nil
+	}
+
+	_, err := dc.cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: internal,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+	return nil
+}
+
+// ConnectNetwork attaches a container to a network, doing nothing when it
+// is attached already.
+func (dc *DockerClient) ConnectNetwork(ctx context.Context, networkName, containerName string) error {
+	err := dc.cli.NetworkConnect(ctx, networkName, containerName, nil)
+	if err != nil && !errdefs.IsConflict(err) && !strings.Contains(err.Error(), "already exists") {
+		return fmt.Errorf("failed to connect %s to network %s: %w", containerName, networkName, err)
+	}
+	return nil
+}
+
+// RemoveNetwork disconnects any remaining containers and removes the
+// network. A network that no longer exists is not an error.
+func (dc *DockerClient) RemoveNetwork(ctx context.Context, name string) error {
+	inspect, err := dc.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect network: %w", err)
+	}
+
+	for id := range inspect.Containers {
+		if err := dc.cli.NetworkDisconnect(ctx, name, id, true); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to disconnect container from network: %w", err)
+		}
+	}
+
+	if err := dc.cli.NetworkRemove(ctx, name); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove network: %w", err)
+	}
+	return nil
+}
+
+func (dc *DockerClient) ListNetworkNames(ctx context.Context) ([]string, error) {
+	networks, err := dc.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	names := make([]string, 0, len(networks))
+	for _, n := range networks {
+		names = append(names, n.Name)
+	}
+	return names, nil
+}